@@ -0,0 +1,199 @@
+package admgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines surrounding each diff hunk.
+const contextLines = 3
+
+// Check compares generated output against the existing file at path.
+// If they differ a unified diff is written to w and false is returned.
+// A missing file is considered different.
+func Check(path string, generated []byte, w io.Writer) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if bytes.Equal(existing, generated) {
+		return true, nil
+	}
+	_, err = io.WriteString(w, Diff(path, path+" (generated)", existing, generated))
+	return false, err
+}
+
+// WriteOutput writes generated output to the file at path.
+// If path is "-" then output is written to stdout.
+func WriteOutput(path string, generated []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(generated)
+		return err
+	}
+	return os.WriteFile(path, generated, 0666)
+}
+
+// edit is a single line of an edit script.
+type edit struct {
+	op   byte // ' ' (equal), '-' (delete from a), or '+' (insert from b)
+	a, b int  // line index into a and b
+}
+
+// Diff returns a unified diff of a and b. An empty string is returned
+// if a and b are the same.
+func Diff(aName, bName string, a, b []byte) string {
+	aLines, bLines := splitLines(a), splitLines(b)
+	edits := diffLines(aLines, bLines)
+
+	var out strings.Builder
+	for i := 0; i < len(edits); {
+		// find the next change
+		for i < len(edits) && edits[i].op == ' ' {
+			i++
+		}
+		if i >= len(edits) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		// extend the hunk until we find enough unchanged lines
+		end, equal := i, 0
+		for end < len(edits) && equal <= contextLines*2 {
+			if edits[end].op == ' ' {
+				equal++
+			} else {
+				equal = 0
+			}
+			end++
+		}
+		if equal > contextLines {
+			end -= equal - contextLines
+		}
+
+		var hunk strings.Builder
+		aStart, bStart, aCount, bCount := edits[start].a, edits[start].b, 0, 0
+		for _, e := range edits[start:end] {
+			var line string
+			switch e.op {
+			case ' ':
+				line = aLines[e.a]
+				aCount++
+				bCount++
+			case '-':
+				line = aLines[e.a]
+				aCount++
+			case '+':
+				line = bLines[e.b]
+				bCount++
+			}
+			hunk.WriteByte(e.op)
+			hunk.WriteString(line)
+			hunk.WriteByte('\n')
+		}
+		if aCount > 0 {
+			aStart++
+		}
+		if bCount > 0 {
+			bStart++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		out.WriteString(hunk.String())
+		i = end
+	}
+	return out.String()
+}
+
+// noNewline marks a last line without a trailing newline. It is part of
+// the line so that it differs from the same line with a newline.
+const noNewline = "\n\\ No newline at end of file"
+
+// splitLines splits b into lines without their newlines.
+func splitLines(b []byte) []string {
+	if len(b) < 1 {
+		return nil
+	}
+	s := string(b)
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if !strings.HasSuffix(s, "\n") {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+// diffLines computes an edit script from a to b using Myers' algorithm.
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	var d int
+search:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack through the trace to build the edit script
+	var edits []edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', x, y})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{'+', x, y})
+		} else {
+			x--
+			edits = append(edits, edit{'-', x, y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{' ', x, y})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,106 @@
+package admgen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+		},
+		{
+			name: "from empty",
+			b:    "a\nb\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "to empty",
+			a:    "a\nb\n",
+			want: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "insert only",
+			a:    "a\nb\nc\nd\n",
+			b:    "a\nb\nX\nc\nd\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,5 @@\n a\n b\n+X\n c\n d\n",
+		},
+		{
+			name: "delete only",
+			a:    "a\nb\nX\nc\nd\n",
+			b:    "a\nb\nc\nd\n",
+			want: "--- a\n+++ b\n@@ -1,5 +1,4 @@\n a\n b\n-X\n c\n d\n",
+		},
+		{
+			name: "trailing newline only",
+			a:    "a\nb\n",
+			b:    "a\nb",
+			want: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name: "trailing newline added",
+			a:    "a",
+			b:    "a\n",
+			want: "--- a\n+++ b\n@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			name: "hunks merged",
+			// six unchanged lines between the changes
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "1\nX\n3\n4\n5\n6\n7\n8\nY\n10\n",
+			want: "--- a\n+++ b\n@@ -1,10 +1,10 @@\n 1\n-2\n+X\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+Y\n 10\n",
+		},
+		{
+			name: "hunks separate",
+			// seven unchanged lines between the changes
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "1\nX\n3\n4\n5\n6\n7\n8\n9\nY\n",
+			want: "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+Y\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if have := Diff("a", "b", []byte(tc.a), []byte(tc.b)); have != tc.want {
+				t.Errorf("have:\n%s\nwant:\n%s", have, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gen.go")
+	if err := os.WriteFile(path, []byte("a\nb\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		generated string
+		ok        bool
+	}{
+		{"a\nb\n", true},
+		{"a\nb", false},
+		{"a\nc\n", false},
+	} {
+		var out strings.Builder
+		ok, err := Check(path, []byte(tc.generated), &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tc.ok {
+			t.Errorf("%q: have %v, want %v", tc.generated, ok, tc.ok)
+		}
+		if !ok && out.Len() == 0 {
+			t.Errorf("%q: expected diff output", tc.generated)
+		}
+	}
+}
@@ -1,15 +1,51 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 
-	"gopkg.in/yaml.v3"
+	"github.com/jessepeterson/admgen"
 )
 
+// generate adds the shared code (unless disabled) and the code of each
+// of cmds to j.
+func (j *jenBuilder) generate(cmds []*Command, overrides *Overrides) {
+	if !j.noShared {
+		j.createShared()
+	}
+
+	for _, cmd := range cmds {
+		name := cmd.Payload.RequestType
+		j.walkCommand(overrides.Apply(name+".payloadkeys", cmd.PayloadKeys), name)
+		if !j.noResponses {
+			j.walkResponse(overrides.Apply(name+".responsekeys", cmd.ResponseKeys), name)
+		}
+	}
+	// the handler is generated along with the responses it handles, by
+	// default only with the shared code so that commands can be generated
+	// into separate files
+	if (!j.noShared || j.handler) && !j.noDependShared && !j.noResponses && len(j.responses) > 0 {
+		j.insertResponseHandler()
+	}
+}
+
+// errNoSchemaPath is returned by schemaRevision without any paths.
+var errNoSchemaPath = errors.New("no schema path")
+
+// schemaRevision reads the schema revision from the checkout containing the
+// first of paths. The paths are of the command YAML and then of the errors so
+// that the shared code generated without any commands records it too.
+func schemaRevision(paths []string) (admgen.Revision, error) {
+	if len(paths) < 1 {
+		return admgen.Revision{}, errNoSchemaPath
+	}
+	return admgen.GitRevision(paths[0])
+}
+
 func main() {
 	var (
 		flPkg         = flag.String("pkg", "main", "Name of generated package")
@@ -17,6 +53,15 @@ func main() {
 		flNoShared    = flag.Bool("no-shared", false, "no \"shared\" code (but depend on it)")
 		flNoDepend    = flag.Bool("no-depend", false, "do not depend on \"shared\"")
 		flNoResponses = flag.Bool("no-responses", false, "do not generate command responses")
+		flOverrides   = flag.String("overrides", "", "YAML file of per-key overrides keyed by schema path")
+		flOverlay     = flag.String("overlay", "", "YAML file of schema patches to apply before generating")
+		flCheck       = flag.Bool("check", false, "check that the output file is up to date; print a diff and exit non-zero if not")
+		flCodec       = flag.String("codec", "", "plist library of the generated default Codec: howett (howett.net/plist) or groob (github.com/groob/plist); none if empty")
+		flErrors      = flag.String("errors", "", "YAML file or directory of MDM error domains to generate an error table and sentinel errors from")
+		flHandler     = flag.Bool("handler", false, "generate the ResponseHandler with -no-shared too; for a single run covering all of the commands")
+
+		flSrc           = flag.String("src", "", "read YAML from a release archive (.zip, .tar.gz) or git ref (<repo-dir>@<ref>)")
+		flSchemaVersion = flag.String("schema-version", "", "schema revision (commit or tag) to record; read from the schema git checkout if empty")
 	)
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <yaml-file>\n", os.Args[0])
@@ -24,57 +69,144 @@ func main() {
 	}
 	flag.Parse()
 
-	var output io.Writer = os.Stdout
+	if *flCheck && *flOut == "-" {
+		fmt.Fprintln(os.Stderr, "error: -check requires an output file")
+		os.Exit(2)
+	}
+
+	if *flErrors != "" && *flNoShared && !*flNoDepend {
+		fmt.Fprintln(os.Stderr, "error: -errors is generated with the shared code and can't be used with -no-shared")
+		os.Exit(2)
+	}
+
+	if _, ok := codecs[*flCodec]; *flCodec != "" && !ok {
+		fmt.Fprintf(os.Stderr, "error: unknown codec: %s\n", *flCodec)
+		os.Exit(2)
+	}
+
 	var err error
-	if *flOut != "-" {
-		output, err = os.OpenFile(*flOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	var overrides *Overrides
+	var overridesName string
+	if *flOverrides != "" {
+		overrides, err = LoadOverrides(*flOverrides)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error opening output file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "error loading overrides: %v\n", err)
 			os.Exit(2)
 		}
+		overridesName = filepath.Base(*flOverrides)
 	}
 
-	var sources []string
-	for _, arg := range flag.Args() {
-		sources = append(sources, filepath.Base(arg))
+	var overlay *admgen.Overlay
+	if *flOverlay != "" {
+		overlay, err = admgen.LoadOverlay(*flOverlay)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading overlay: %v\n", err)
+			os.Exit(2)
+		}
 	}
 
-	j := newJenBuilder(*flPkg, sources, *flNoShared, *flNoDepend, *flNoResponses)
-
-	if !*flNoShared {
-		j.createShared()
+	src, err := admgen.OpenSource(*flSrc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening source: %v\n", err)
+		os.Exit(2)
 	}
 
+	type source struct {
+		path string
+		data []byte
+	}
+	var sources []source
+	var sourceNames []string
 	for _, arg := range flag.Args() {
-		f, err := os.Open(arg)
+		data, err := src.ReadFile(arg)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error reading YAML: %v\n", err)
 			continue
 		}
+		sources = append(sources, source{path: arg, data: data})
+		sourceNames = append(sourceNames, fmt.Sprintf("%s (%s)", filepath.Base(arg), admgen.ContentHash(data)))
+	}
 
-		cmd := new(Command)
+	rev := admgen.Revision{Commit: *flSchemaVersion}
+	if rev.Commit == "" && *flSrc != "" {
+		rev = src.Revision
+	} else if rev.Commit == "" {
+		var paths []string
+		for _, src := range sources {
+			paths = append(paths, src.path)
+		}
+		if *flErrors != "" {
+			paths = append(paths, *flErrors)
+		}
+		rev, err = schemaRevision(paths)
+		if errors.Is(err, errNoSchemaPath) && !*flNoShared {
+			// the shared code records the revision in SchemaVersion
+			fmt.Fprintln(os.Stderr, "error: can't read the schema revision without YAML inputs or -errors; use -schema-version")
+			os.Exit(2)
+		} else if err != nil && !errors.Is(err, errNoSchemaPath) && !errors.Is(err, admgen.ErrNoRepository) {
+			fmt.Fprintf(os.Stderr, "warning: reading schema revision: %v\n", err)
+		}
+	}
 
-		err = yaml.NewDecoder(f).Decode(cmd)
+	var errorDomains []ErrorDomain
+	if *flErrors != "" {
+		var errorsName string
+		errorDomains, errorsName, err = loadErrors(src, *flErrors, overlay)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error decoding YAML: %v\n", err)
-			continue
+			fmt.Fprintf(os.Stderr, "error loading errors: %v\n", err)
+			os.Exit(2)
 		}
-
-		j.walkCommand(cmd.PayloadKeys, cmd.Payload.RequestType)
-		if !*flNoResponses {
-			j.walkResponse(cmd.ResponseKeys, cmd.Payload.RequestType)
+		if errorDomains == nil {
+			errorDomains = []ErrorDomain{}
 		}
+		sourceNames = append(sourceNames, errorsName)
+	}
+
+	j := newJenBuilder(*flPkg, sourceNames, *flNoShared, *flNoDepend, *flNoResponses, overridesName, *flCodec, rev)
+	j.errors = errorDomains
+	j.handler = *flHandler
 
-		err = f.Close()
+	var cmds []*Command
+	for _, src := range sources {
+		cmd := new(Command)
+
+		err = overlay.Decode(bytes.NewReader(src.data), src.path, cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error closing YAML: %v\n", err)
+			fmt.Fprintf(os.Stderr, "error decoding YAML: %v\n", err)
 			continue
 		}
+		cmds = append(cmds, cmd)
+	}
+	j.generate(cmds, overrides)
+	j.patches(overlay.Applied())
+	for _, name := range overlay.Unused() {
+		fmt.Fprintf(os.Stderr, "warning: patch not applied: %s\n", name)
 	}
+	for _, path := range overrides.Unused() {
+		fmt.Fprintf(os.Stderr, "warning: override not applied: %s\n", path)
+	}
+
+	output := new(bytes.Buffer)
 	err = j.file.Render(output)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error rendering output: %v\n", err)
 		os.Exit(2)
 	}
 
+	if *flCheck {
+		ok, err := admgen.Check(*flOut, output.Bytes(), os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error checking output file: %v\n", err)
+			os.Exit(2)
+		} else if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = admgen.WriteOutput(*flOut, output.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing output file: %v\n", err)
+		os.Exit(2)
+	}
 }
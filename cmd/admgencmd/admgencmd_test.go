@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessepeterson/admgen"
+	"github.com/jessepeterson/admgen/internal/gentest"
+)
+
+// options are the command line options of a generated test file.
+type options struct {
+	noShared, noDepend, noResponses bool
+	errors                          bool
+	handler                         bool
+	commands                        []string
+	// overrides file, if any
+	overrides string
+	// -codec
+	codec string
+}
+
+func generate(t *testing.T, o options) []byte {
+	t.Helper()
+	var cmds []*Command
+	for _, name := range o.commands {
+		path := filepath.Join("../../testdata/schema/mdm/commands", name)
+		cmd := new(Command)
+		if err := (*admgen.Overlay)(nil).Decode(bytes.NewReader(gentest.ReadFile(t, path)), path, cmd); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	var overrides *Overrides
+	var overridesName string
+	if o.overrides != "" {
+		var err error
+		if overrides, err = LoadOverrides(o.overrides); err != nil {
+			t.Fatal(err)
+		}
+		overridesName = filepath.Base(o.overrides)
+	}
+	j := newJenBuilder("mdm", nil, o.noShared, o.noDepend, o.noResponses, overridesName, o.codec, admgen.Revision{Tag: "test"})
+	if o.errors {
+		errors, _, err := loadErrors(nil, "../../testdata/schema/mdm/errors", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j.errors = errors
+	}
+	j.handler = o.handler
+	j.generate(cmds, overrides)
+	var b bytes.Buffer
+	if err := j.file.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+var allCommands = []string{"application.install.yaml", "information.device.yaml"}
+
+func TestGenerated(t *testing.T) {
+	generated := generate(t, options{errors: true, commands: allCommands})
+	gentest.Golden(t, "testdata/cmd.go.golden", generated)
+	gentest.Run(t, map[string][]byte{
+		"cmd.go":      generated,
+		"cmd_test.go": gentest.ReadFile(t, "testdata/cmd_test.go"),
+	})
+}
+
+func TestGeneratedShared(t *testing.T) {
+	// shared code without commands, e.g. for generating commands separately
+	shared := generate(t, options{errors: true})
+	gentest.Golden(t, "testdata/shared.go.golden", shared)
+	gentest.Run(t, map[string][]byte{"shared.go": shared})
+
+	commands := generate(t, options{noShared: true, handler: true, commands: allCommands})
+	gentest.Golden(t, "testdata/commands.go.golden", commands)
+	gentest.Run(t, map[string][]byte{
+		"shared.go":   shared,
+		"commands.go": commands,
+		"cmd_test.go": gentest.ReadFile(t, "testdata/cmd_test.go"),
+	})
+
+	// one file per command, without the handler
+	files := map[string][]byte{"shared.go": shared}
+	for _, name := range allCommands {
+		files[name+".go"] = generate(t, options{noShared: true, commands: []string{name}})
+	}
+	gentest.Run(t, files)
+}
+
+func TestGeneratedOverrides(t *testing.T) {
+	generated := generate(t, options{commands: allCommands, overrides: "testdata/overrides.yaml"})
+	gentest.Golden(t, "testdata/overrides.go.golden", generated)
+	gentest.Run(t, map[string][]byte{"overrides.go": generated})
+}
+
+func TestGeneratedCodec(t *testing.T) {
+	for _, name := range []string{"howett", "groob"} {
+		t.Run(name, func(t *testing.T) {
+			generated := generate(t, options{commands: allCommands, codec: name})
+			gentest.Golden(t, "testdata/codec_"+name+".go.golden", generated)
+			// the plist libraries are replaced by local stand-ins
+			gentest.Run(t, map[string][]byte{
+				"go.mod": []byte("module gentest\n\ngo 1.19\n\n" +
+					"require (\n\tgithub.com/groob/plist v0.0.0\n\thowett.net/plist v0.0.0\n)\n\n" +
+					"replace github.com/groob/plist => ./groob\n\nreplace howett.net/plist => ./howett\n"),
+				"howett/go.mod":   gentest.ReadFile(t, "testdata/codec/howett/go.mod"),
+				"howett/plist.go": gentest.ReadFile(t, "testdata/codec/howett/plist.go"),
+				"groob/go.mod":    gentest.ReadFile(t, "testdata/codec/groob/go.mod"),
+				"groob/plist.go":  gentest.ReadFile(t, "testdata/codec/groob/plist.go"),
+				"codec.go":        generated,
+				"codec_test.go":   gentest.ReadFile(t, "testdata/codec_test.go"),
+			})
+		})
+	}
+}
+
+func TestSchemaRevision(t *testing.T) {
+	const commit = "1111111111111111111111111111111111111111"
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		".git/HEAD":                  commit + "\n",
+		"mdm/commands/a.yaml":        "",
+		"mdm/errors/mdm.yaml":        "",
+		"vendor/mdm/commands/a.yaml": "",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		name    string
+		paths   []string
+		want    string
+		wantErr error
+	}{
+		{"commands", []string{"mdm/commands/a.yaml", "mdm/errors"}, commit, nil},
+		{"errors only", []string{"mdm/errors"}, commit, nil},
+		{"none", nil, "", errNoSchemaPath},
+		{"not the schema checkout", []string{"vendor/mdm/commands/a.yaml"}, "", admgen.ErrNotSchemaRepository},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var paths []string
+			for _, path := range tc.paths {
+				paths = append(paths, filepath.Join(dir, filepath.FromSlash(path)))
+			}
+			rev, err := schemaRevision(paths)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("have error %v, want %v", err, tc.wantErr)
+			}
+			if rev.Commit != tc.want {
+				t.Errorf("have commit %q, want %q", rev.Commit, tc.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	. "github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
 )
 
 // Key represents the "key" type of the Apple Device Management YAML.
@@ -18,6 +19,8 @@ type Key struct {
 
 	// used to override the name (and plist key) of the field for a dictionary type
 	keyOverride string
+	// used to override only the name of the field for a dictionary type
+	fieldName string
 	// whether to include the Content (aka comment) on a field comment
 	includeContent bool
 	// whether this comment only applies to the struct itself
@@ -49,16 +52,50 @@ type jenBuilder struct {
 	noShared       bool
 	noDependShared bool
 	noResponses    bool
+	// generate the ResponseHandler also with noShared
+	handler bool
+
+	schema admgen.Revision
+
+	// known MDM errors to generate, if any
+	errors         []ErrorDomain
+	errorsInserted bool
+
+	responseErrorInserted bool
+	statusInserted        bool
+
+	// plist library of the generated default Codec, if any
+	codec string
+
+	// names of the commands whose responses were walked
+	responses []string
+
+	// fields of the generated structs by struct name
+	fields map[string][]structField
+}
+
+// structField is a field of a generated struct.
+type structField struct {
+	name string
+	// type of the field, or of the pointed-to value if pointer
+	elem    *Statement
+	pointer bool
 }
 
-func newJenBuilder(pkgName string, sources []string, noShared, noDependShared, noResponse bool) *jenBuilder {
+func newJenBuilder(pkgName string, sources []string, noShared, noDependShared, noResponse bool, overrides, codec string, schema admgen.Revision) *jenBuilder {
 	j := &jenBuilder{
 		file:           NewFile(pkgName),
 		noShared:       noShared,
 		noDependShared: noDependShared,
 		noResponses:    noResponse,
+		schema:         schema,
+		codec:          codec,
+		fields:         make(map[string][]structField),
 	}
 	j.file.PackageComment("Code generated by \"admgencmd\"; DO NOT EDIT.")
+	if schema.Version() != "" {
+		j.file.PackageComment("Schema: " + schema.String())
+	}
 	if len(sources) >= 1 {
 		plural := ""
 		if len(sources) > 1 {
@@ -76,12 +113,25 @@ func newJenBuilder(pkgName string, sources []string, noShared, noDependShared, n
 	if j.noResponses {
 		options = append(options, "no-responses=true")
 	}
+	if overrides != "" {
+		options = append(options, "overrides="+overrides)
+	}
+	if codec != "" {
+		options = append(options, "codec="+codec)
+	}
 	if len(options) >= 1 {
 		j.file.PackageComment("Options: " + strings.Join(options, ","))
 	}
 	return j
 }
 
+// patches notes the names of applied schema patches in the generated header.
+func (j *jenBuilder) patches(names []string) {
+	if len(names) >= 1 {
+		j.file.PackageComment("Patches: " + strings.Join(names, ", "))
+	}
+}
+
 var commandUUIDKey = Key{
 	Key:      "CommandUUID",
 	Type:     "<string>",
@@ -106,11 +156,69 @@ var networkTetherKey = Key{
 	Presence: "optional",
 }
 
+// statuses are the values of the Status of a response.
+var statuses = []struct {
+	name    string
+	comment string
+}{
+	{"Acknowledged", "the command was processed successfully."},
+	{"Error", "an error occurred processing the command."},
+	{"CommandFormatError", "the command was malformed."},
+	{"Idle", "the device has no command result to report and is ready for a command."},
+	{"NotNow", "the device can't process the command now and it should be sent again later."},
+}
+
 var statusKey = Key{
-	Key:       "Status",
-	Type:      "<string>",
-	Presence:  "required",
-	RangeList: []string{"Acknowledged", "Error", "CommandFormatError", "Idle", "NotNow"},
+	Key:          "Status",
+	Type:         "Status",
+	Presence:     "required",
+	forceRawType: true,
+}
+
+// insertStatus generates the Status type of responses.
+func insertStatus(j *jenBuilder) {
+	if j.statusInserted {
+		return
+	}
+	j.statusInserted = true
+
+	j.file.Comment("Status is the status of an MDM command response.")
+	j.file.Type().Id("Status").String()
+
+	var consts []Code
+	for _, st := range statuses {
+		consts = append(consts,
+			Comment("Status"+st.name+" means "+st.comment),
+			Id("Status"+st.name).Id("Status").Op("=").Lit(st.name),
+		)
+	}
+	j.file.Const().Defs(consts...)
+
+	j.file.Comment("IsTerminal reports whether the command is finished and should be removed")
+	j.file.Comment("from the queue: it was acknowledged or failed.")
+	j.file.Func().Params(Id("s").Id("Status")).Id("IsTerminal").Params().Bool().Block(
+		Return(Id("s").Op("==").Id("StatusAcknowledged").Op("||").Id("s").Dot("IsError").Call()),
+	)
+
+	j.file.Comment("IsError reports whether the command failed.")
+	j.file.Func().Params(Id("s").Id("Status")).Id("IsError").Params().Bool().Block(
+		Return(Id("s").Op("==").Id("StatusError").Op("||").Id("s").Op("==").Id("StatusCommandFormatError")),
+	)
+
+	j.file.Comment("ShouldRetry reports whether the command should be sent again later.")
+	j.file.Func().Params(Id("s").Id("Status")).Id("ShouldRetry").Params().Bool().Block(
+		Return(Id("s").Op("==").Id("StatusNotNow")),
+	)
+}
+
+// insertStatusHelpers generates the Status helper methods on the response name.
+func insertStatusHelpers(name string, j *jenBuilder) {
+	for _, m := range []string{"IsTerminal", "IsError", "ShouldRetry"} {
+		j.file.Comment(m + " calls " + m + " on the Status of r.")
+		j.file.Func().Params(Id("r").Op("*").Id(name)).Id(m).Params().Bool().Block(
+			Return(Id("r").Dot("Status").Dot(m).Call()),
+		)
+	}
 }
 
 var notConsKey = Key{
@@ -129,6 +237,16 @@ var errorChainKey = Key{
 func insertErrorChain(j *jenBuilder) {
 	j.handleKey(errorChainItem, "")
 
+	errorsFallback := Null()
+	if j.errors != nil {
+		// use the known error description if the client didn't send one
+		errorsFallback = If(Id("errStr").Op("==").Lit("")).Block(
+			If(List(Id("d"), Id("ok")).Op(":=").Id("Errors").Index(Parens(Op("*").Id("ec")).Index(Id("i")).Dot("Code").Call()), Id("ok")).Block(
+				Id("errStr").Op("=").Id("d").Dot("Description"),
+			),
+		)
+	}
+
 	j.file.Comment("ErrorChain represents any errors that occured on the client executing an MDM command.")
 	j.file.Type().Id("ErrorChain").Index().Id("ErrorChainItem")
 
@@ -152,6 +270,7 @@ func insertErrorChain(j *jenBuilder) {
 			Comment("the searchability of error messages is often more successful"),
 			Comment("with the US english versions"),
 			Id("errStr").Op(":=").Parens(Op("*").Id("ec")).Index(Id("i")).Dot("USEnglishDescription"),
+			errorsFallback,
 			If(Id("errStr").Op("==").Lit("")).Block(
 				Id("errStr").Op("=").Parens(Op("*").Id("ec")).Index(Id("i")).Dot("LocalizedDescription"),
 			),
@@ -164,6 +283,11 @@ func insertErrorChain(j *jenBuilder) {
 		),
 		Return(Id("s")),
 	)
+
+	if j.errors != nil && !j.errorsInserted {
+		j.insertErrors()
+		j.errorsInserted = true
+	}
 }
 
 var enrollmentKey = Key{
@@ -233,6 +357,21 @@ func (j *jenBuilder) createShared() {
 	}
 	j.handleKey(cmd, "")
 
+	j.file.Comment("SchemaVersion is the Apple Device Management schema revision this code was generated from.")
+	j.file.Const().Id("SchemaVersion").Op("=").Lit(j.schema.Version())
+
+	insertCommandMethods(cmd.Key, j)
+
+	j.file.Comment("Command is implemented by all MDM commands.")
+	j.file.Type().Id("Command").Interface(
+		Id("RequestType").Params().String(),
+		Id("UUID").Params().String(),
+		Id("SetUUID").Params(Id("uuid").String()),
+		Comment("Payload returns a pointer to the \"inner\" command payload"),
+		Id("Payload").Params().Interface(),
+		Id("RequiresNetworkTether").Params().Bool(),
+	)
+
 	// create the interface for the generic command
 	j.file.Comment("GenericCommanders can extract a GenericCommand.")
 	j.file.Type().Id("GenericCommander").Interface(
@@ -285,6 +424,7 @@ func (j *jenBuilder) createShared() {
 		)
 
 		insertErrorChain(j)
+		insertStatus(j)
 
 		j.handleKey(enrollment, "")
 
@@ -305,8 +445,15 @@ func (j *jenBuilder) createShared() {
 
 		j.handleKey(response, "")
 
-		insertValidate(response.Key, j)
+		insertResponseError(j)
+		insertValidate(response.Key, "", j)
+		insertStatusHelpers(response.Key, j)
+
+		insertResponseDecoder(j)
+		insertPairs(j)
 	}
+
+	insertCodec(j)
 }
 
 func (j *jenBuilder) walkCommand(keys []Key, name string) {
@@ -359,6 +506,8 @@ func (j *jenBuilder) walkCommand(keys []Key, name string) {
 	// Go (hah) convert it to code now
 	j.handleKey(cmd, "")
 
+	insertCommandMethods(cmd.Key, j)
+
 	if !j.noDependShared {
 		// create a helper method to return a copy of a generic command
 		j.file.Comment("GenericCommand creates a new generic command using the values of c.")
@@ -374,15 +523,22 @@ func (j *jenBuilder) walkCommand(keys []Key, name string) {
 		)
 	}
 
+	j.insertCommandOptions(name, payload.Key)
+
 	// create a helper function to instantiate our command with the correct RequestType
 	j.file.Comment("New" + cmd.Key + " creates a new \"" + name + "\" Apple MDM command.")
-	j.file.Func().Id("New" + cmd.Key).Params(Id("uuid").String()).Op("*").Id(cmd.Key).Block(
-		Return(Op("&").Id(cmd.Key).Values(Dict{
+	j.file.Comment("The opts are applied to the command in order.")
+	j.file.Func().Id("New"+cmd.Key).Params(Id("uuid").String(), Id("opts").Op("...").Id(name+"Option")).Op("*").Id(cmd.Key).Block(
+		Id("c").Op(":=").Op("&").Id(cmd.Key).Values(Dict{
 			Id("Command"): Id(payload.Key).Values(Dict{
 				Id("RequestType"): Id(name + "RequestType"),
 			}),
 			Id("CommandUUID"): Id("uuid"),
-		})),
+		}),
+		For(List(Id("_"), Id("opt")).Op(":=").Range().Id("opts")).Block(
+			Id("opt").Call(Id("c")),
+		),
+		Return(Id("c")),
 	)
 
 	// create a helper function for instantiating command structs
@@ -397,22 +553,169 @@ func (j *jenBuilder) walkCommand(keys []Key, name string) {
 	}
 }
 
-func insertValidate(name string, j *jenBuilder) {
+// insertCommandOptions generates an option type and an option function
+// for each field of the payload (named payloadName) of the command name.
+func (j *jenBuilder) insertCommandOptions(name, payloadName string) {
+	fields, ok := j.fields[payloadName]
+	if !ok {
+		// the shared payload was generated elsewhere (e.g. with -no-shared)
+		elem, _, pointer := j.handleKeyElem(networkTetherKey, "<dictionary>")
+		fields = []structField{{name: networkTetherKey.Key, elem: elem, pointer: pointer}}
+	}
+
+	j.file.Comment(name + "Option sets a payload field of the \"" + name + "\" command.")
+	j.file.Type().Id(name + "Option").Func().Params(Op("*").Id(name + "Command"))
+
+	for _, f := range fields {
+		if f.name == "RequestType" {
+			continue
+		}
+		body := []Code{Id("c").Dot("Command").Dot(f.name).Op("=").Id("v")}
+		if f.pointer {
+			// copy v so that commands created with the same option don't
+			// share the value
+			body = []Code{
+				Id("v").Op(":=").Id("v"),
+				Id("c").Dot("Command").Dot(f.name).Op("=").Op("&").Id("v"),
+			}
+		}
+		j.file.Comment(name + "With" + f.name + " sets the " + f.name + " of the command to v.")
+		j.file.Func().Id(name + "With" + f.name).Params(Id("v").Add(f.elem)).Id(name + "Option").Block(
+			Return(Func().Params(Id("c").Op("*").Id(name + "Command")).Block(body...)),
+		)
+	}
+}
+
+// insertCommandMethods generates the methods of the Command interface
+// for the command name.
+func insertCommandMethods(name string, j *jenBuilder) {
+	if !j.noShared || !j.noDependShared {
+		j.file.Var().Id("_").Id("Command").Op("=").Parens(Op("*").Id(name)).Parens(Nil())
+		j.file.Line()
+	}
+
+	j.file.Comment("RequestType returns the RequestType of c.")
+	j.file.Func().Params(Id("c").Op("*").Id(name)).Id("RequestType").Params().String().Block(
+		Return(Id("c").Dot("Command").Dot("RequestType")),
+	)
+
+	j.file.Comment("UUID returns the CommandUUID of c.")
+	j.file.Func().Params(Id("c").Op("*").Id(name)).Id("UUID").Params().String().Block(
+		Return(Id("c").Dot("CommandUUID")),
+	)
+
+	j.file.Comment("SetUUID sets the CommandUUID of c.")
+	j.file.Func().Params(Id("c").Op("*").Id(name)).Id("SetUUID").Params(Id("uuid").String()).Block(
+		Id("c").Dot("CommandUUID").Op("=").Id("uuid"),
+	)
+
+	j.file.Comment("Payload returns a pointer to the \"inner\" command payload of c.")
+	j.file.Func().Params(Id("c").Op("*").Id(name)).Id("Payload").Params().Interface().Block(
+		Return(Op("&").Id("c").Dot("Command")),
+	)
+
+	j.file.Comment("RequiresNetworkTether reports whether c requires a network tether.")
+	j.file.Func().Params(Id("c").Op("*").Id(name)).Id("RequiresNetworkTether").Params().Bool().Block(
+		Return(Id("c").Dot("Command").Dot("RequestRequiresNetworkTether").Op("!=").Nil().Op("&&").Op("*").Id("c").Dot("Command").Dot("RequestRequiresNetworkTether")),
+	)
+}
+
+func insertValidate(name, requestType string, j *jenBuilder) {
+	var rt Code = Lit("")
+	if requestType != "" {
+		rt = Id(requestType + "RequestType")
+	}
 	j.file.Comment("Validate checks for any command response errors.")
+	j.file.Comment("Any error returned is a *ResponseError.")
 	j.file.Func().Params(
 		Id("r").Op("*").Id(name),
 	).Id("Validate").Params().Error().Block(
-		If(Id("r").Dot("ErrorChain").Op("!=").Nil().Op("||").Parens(Id("r").Dot("Status").Op("!=").Lit("Acknowledged").Op("&&").Id("r").Dot("Status").Op("!=").Lit("Idle").Op("&&").Id("r").Dot("Status").Op("!=").Lit("NotNow"))).Block(
-			Return(Qual("fmt", "Errorf").Params(
-				Lit("MDM error for status %s: %w"),
-				Id("r").Dot("Status"),
-				Id("r").Dot("ErrorChain")),
-			),
+		If(Id("r").Dot("ErrorChain").Op("!=").Nil().Op("||").Parens(Id("r").Dot("Status").Op("!=").Id("StatusAcknowledged").Op("&&").Id("r").Dot("Status").Op("!=").Id("StatusIdle").Op("&&").Id("r").Dot("Status").Op("!=").Id("StatusNotNow"))).Block(
+			Return(Op("&").Id("ResponseError").Values(Dict{
+				Id("RequestType"):  rt,
+				Id("CommandUUID"):  Id("r").Dot("CommandUUID"),
+				Id("Status"):       Id("r").Dot("Status"),
+				Id("NotOnConsole"): Id("r").Dot("NotOnConsole"),
+				Id("ErrorChain"):   Id("r").Dot("ErrorChain"),
+			})),
 		),
 		Return(Nil()),
 	)
 }
 
+// insertResponseError generates the error type returned by the Validate
+// methods of responses.
+func insertResponseError(j *jenBuilder) {
+	if j.responseErrorInserted {
+		return
+	}
+	j.responseErrorInserted = true
+
+	j.file.Comment("ResponseError is an MDM command response that reported an error.")
+	j.file.Type().Id("ResponseError").Struct(
+		Comment("empty for generic responses"),
+		Id("RequestType").String(),
+		Id("CommandUUID").String(),
+		Id("Status").Id("Status"),
+		Id("NotOnConsole").Bool(),
+		Comment("may be nil"),
+		Id("ErrorChain").Op("*").Id("ErrorChain"),
+	)
+
+	j.file.Comment("ResponseStatusError is a sentinel error matching any *ResponseError with")
+	j.file.Comment("its Status using errors.Is.")
+	j.file.Type().Id("ResponseStatusError").Id("Status")
+
+	j.file.Const().Defs(
+		Comment("ErrStatusError matches any *ResponseError with a Status of \"Error\"."),
+		Id("ErrStatusError").Op("=").Id("ResponseStatusError").Call(Id("StatusError")),
+		Comment("ErrStatusCommandFormatError matches any *ResponseError with a Status of \"CommandFormatError\"."),
+		Id("ErrStatusCommandFormatError").Op("=").Id("ResponseStatusError").Call(Id("StatusCommandFormatError")),
+	)
+
+	j.file.Comment("Error adapts a standard Go error for ResponseStatusError.")
+	j.file.Func().Params(Id("e").Id("ResponseStatusError")).Id("Error").Params().String().Block(
+		Return(Lit("MDM error for status ").Op("+").String().Call(Id("e"))),
+	)
+
+	j.file.Comment("Error adapts a standard Go error for ResponseError.")
+	j.file.Func().Params(Id("e").Op("*").Id("ResponseError")).Id("Error").Params().String().Block(
+		Id("s").Op(":=").Lit("MDM error for status ").Op("+").String().Call(Id("e").Dot("Status")),
+		If(Id("e").Dot("RequestType").Op("!=").Lit("")).Block(
+			Id("s").Op("=").Qual("fmt", "Sprintf").Call(Lit("MDM error for %s command %s status %s"), Id("e").Dot("RequestType"), Id("e").Dot("CommandUUID"), Id("e").Dot("Status")),
+		),
+		If(Id("e").Dot("ErrorChain").Op("!=").Nil()).Block(
+			Id("s").Op("+=").Lit(": ").Op("+").Id("e").Dot("ErrorChain").Dot("Error").Call(),
+		),
+		Return(Id("s")),
+	)
+
+	j.file.Comment("Unwrap returns the ErrorChain of e, if any.")
+	j.file.Func().Params(Id("e").Op("*").Id("ResponseError")).Id("Unwrap").Params().Error().Block(
+		If(Id("e").Dot("ErrorChain").Op("==").Nil()).Block(
+			Return(Nil()),
+		),
+		Return(Id("e").Dot("ErrorChain")),
+	)
+
+	j.file.Comment("Is reports whether e matches target, a ResponseStatusError with the Status")
+	j.file.Comment("of e (for example errors.Is(err, ErrStatusCommandFormatError)) or a")
+	j.file.Comment("*ResponseError whose non-empty RequestType, CommandUUID, and Status")
+	j.file.Comment("fields match e.")
+	j.file.Func().Params(Id("e").Op("*").Id("ResponseError")).Id("Is").Params(Id("target").Error()).Bool().Block(
+		If(List(Id("s"), Id("ok")).Op(":=").Id("target").Assert(Id("ResponseStatusError")), Id("ok")).Block(
+			Return(Id("Status").Call(Id("s")).Op("==").Id("e").Dot("Status")),
+		),
+		List(Id("t"), Id("ok")).Op(":=").Id("target").Assert(Op("*").Id("ResponseError")),
+		If(Op("!").Id("ok").Op("||").Id("t").Op("==").Nil()).Block(
+			Return(False()),
+		),
+		Return(Parens(Id("t").Dot("RequestType").Op("==").Lit("").Op("||").Id("t").Dot("RequestType").Op("==").Id("e").Dot("RequestType")).Op("&&").Line().
+			Parens(Id("t").Dot("CommandUUID").Op("==").Lit("").Op("||").Id("t").Dot("CommandUUID").Op("==").Id("e").Dot("CommandUUID")).Op("&&").Line().
+			Parens(Id("t").Dot("Status").Op("==").Lit("").Op("||").Id("t").Dot("Status").Op("==").Id("e").Dot("Status"))),
+	)
+}
+
 func (j *jenBuilder) walkResponse(keys []Key, name string) {
 	response := Key{
 		Key:     name + "Response",
@@ -425,6 +728,7 @@ func (j *jenBuilder) walkResponse(keys []Key, name string) {
 	}
 	if j.noDependShared {
 		insertErrorChain(j)
+		insertStatus(j)
 		j.handleKey(enrollment, "")
 
 		response.SubKeys = append(response.SubKeys,
@@ -448,7 +752,13 @@ func (j *jenBuilder) walkResponse(keys []Key, name string) {
 	}
 	j.handleKey(response, "")
 
-	insertValidate(response.Key, j)
+	if j.noDependShared {
+		insertResponseError(j)
+	}
+	insertValidate(response.Key, name, j)
+	if j.noDependShared {
+		insertStatusHelpers(response.Key, j)
+	}
 
 	if !j.noDependShared {
 		// create a helper method to return a copy of a generic command
@@ -462,6 +772,9 @@ func (j *jenBuilder) walkResponse(keys []Key, name string) {
 			// Return(Id("cmd")),
 			Return(Op("&").Id("r.GenericResponse")),
 		)
+
+		insertPair(name, j)
+		j.responses = append(j.responses, name)
 	}
 
 	// create a helper function for instantiating response structs
@@ -476,7 +789,17 @@ func (j *jenBuilder) walkResponse(keys []Key, name string) {
 	}
 }
 
-func (j *jenBuilder) handleKey(key Key, parentType string) (s *Statement, comment string) {
+func (j *jenBuilder) handleKey(key Key, parentType string) (*Statement, string) {
+	s, comment, pointer := j.handleKeyElem(key, parentType)
+	if pointer {
+		s = Op("*").Add(s)
+	}
+	return s, comment
+}
+
+// handleKeyElem is like handleKey but reports whether the type should be
+// a pointer rather than adding it.
+func (j *jenBuilder) handleKeyElem(key Key, parentType string) (s *Statement, comment string, pointer bool) {
 	switch key.Type {
 	case "<string>":
 		s = String()
@@ -500,9 +823,9 @@ func (j *jenBuilder) handleKey(key Key, parentType string) (s *Statement, commen
 					comment += ", "
 				}
 				comment += "assuming string map for single dictionary subkey"
-				return Map(String()).Op("*").Id(k.Key), comment
+				return Map(String()).Op("*").Id(k.Key), comment, false
 			case "<any>":
-				return Interface(), "<any> type as single dictionary subkey"
+				return Interface(), "<any> type as single dictionary subkey", false
 			}
 		}
 		s, comment = j.handleDict(key)
@@ -511,7 +834,7 @@ func (j *jenBuilder) handleKey(key Key, parentType string) (s *Statement, commen
 		s = Index().Add(s)
 	default:
 		if key.forceRawType {
-			s = Id(key.Type)
+			s = rawType(key.Type)
 		} else {
 			s = Interface()
 			comment = "unknown type: " + key.Type
@@ -527,12 +850,36 @@ func (j *jenBuilder) handleKey(key Key, parentType string) (s *Statement, commen
 		}
 		comment += begin + ": " + strings.Join(key.RangeList, ", ")
 	}
-	if parentType != "<array>" && s != nil && key.Presence != "required" {
-		s = Op("*").Add(s)
+	pointer = parentType != "<array>" && s != nil && key.Presence != "required"
+	if key.forceRawType && (strings.HasPrefix(key.Type, "*") || strings.HasPrefix(key.Type, "[]")) {
+		// already a pointer or slice
+		pointer = false
 	}
 	return
 }
 
+// rawType returns the Go type t of an override. t may be package
+// qualified and have pointer and slice prefixes (e.g. "*pkg/path.T").
+func rawType(t string) *Statement {
+	s := new(Statement)
+	for {
+		if strings.HasPrefix(t, "*") {
+			s.Op("*")
+			t = t[1:]
+		} else if strings.HasPrefix(t, "[]") {
+			s.Index()
+			t = t[2:]
+		} else {
+			break
+		}
+	}
+	if i := strings.LastIndex(t, "."); i > 0 {
+		// package qualified type
+		return s.Qual(t[:i], t[i+1:])
+	}
+	return s.Id(t)
+}
+
 func (j *jenBuilder) handleArray(key Key) (s *Statement, comment string) {
 	keys := key.SubKeys
 	if len(keys) < 1 {
@@ -575,17 +922,24 @@ func (j *jenBuilder) handleArray(key Key) (s *Statement, comment string) {
 func (j *jenBuilder) handleDict(key Key) (s *Statement, comment string) {
 	var fields []Code
 	for _, k := range key.SubKeys {
-		s, comment := j.handleKey(k, key.Type)
-		if s == nil {
+		elem, comment, pointer := j.handleKeyElem(k, key.Type)
+		if elem == nil {
 			panic("handleKey should not have returned nil")
 		}
+		s := elem
+		if pointer {
+			s = Op("*").Add(elem)
+		}
 		fieldName := normalizeFieldName(k.Key)
 		if k.keyOverride != "" {
 			fieldName = k.keyOverride
+		} else if k.fieldName != "" {
+			fieldName = k.fieldName
 		}
 		var jenField *Statement
 		if !k.embeddedStruct {
 			jenField = Id(fieldName).Add(s)
+			j.fields[key.Key] = append(j.fields[key.Key], structField{name: fieldName, elem: elem, pointer: pointer})
 		} else {
 			jenField = s
 		}
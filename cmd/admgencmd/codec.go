@@ -0,0 +1,96 @@
+package main
+
+import (
+	. "github.com/dave/jennifer/jen"
+)
+
+// codecs are the plist libraries a default Codec can be generated for.
+var codecs = map[string]struct {
+	path string
+	// generates the Marshal and Unmarshal method bodies
+	marshal, unmarshal func(pkg string) []Code
+}{
+	"howett": {
+		path: "howett.net/plist",
+		marshal: func(pkg string) []Code {
+			return []Code{Return(Qual(pkg, "Marshal").Call(Id("v"), Qual(pkg, "XMLFormat")))}
+		},
+		unmarshal: func(pkg string) []Code {
+			return []Code{
+				List(Id("_"), Id("err")).Op(":=").Qual(pkg, "Unmarshal").Call(Id("data"), Id("v")),
+				Return(Id("err")),
+			}
+		},
+	},
+	"groob": {
+		path: "github.com/groob/plist",
+		marshal: func(pkg string) []Code {
+			return []Code{Return(Qual(pkg, "Marshal").Call(Id("v")))}
+		},
+		unmarshal: func(pkg string) []Code {
+			return []Code{Return(Qual(pkg, "Unmarshal").Call(Id("data"), Id("v")))}
+		},
+	},
+}
+
+// insertCodec generates the Codec interface, the default Codec (if any),
+// and the helpers that use it.
+func insertCodec(j *jenBuilder) {
+	j.file.Comment("Codec encodes and decodes the plists of MDM commands and responses.")
+	j.file.Type().Id("Codec").Interface(
+		Id("Marshal").Params(Id("v").Interface()).Params(Index().Byte(), Error()),
+		Id("Unmarshal").Params(Id("data").Index().Byte(), Id("v").Interface()).Error(),
+	)
+
+	j.file.Comment("ErrNoCodec is returned when DefaultCodec is not set.")
+	j.file.Var().Id("ErrNoCodec").Op("=").Qual("errors", "New").Call(Lit("no codec"))
+
+	if c, ok := codecs[j.codec]; ok {
+		j.file.Comment("PlistCodec is a Codec using the " + c.path + " package.")
+		j.file.Type().Id("PlistCodec").Struct()
+
+		j.file.Comment("Marshal encodes v as an XML plist.")
+		j.file.Func().Params(Id("PlistCodec")).Id("Marshal").Params(Id("v").Interface()).Params(Index().Byte(), Error()).Block(
+			c.marshal(c.path)...,
+		)
+
+		j.file.Comment("Unmarshal decodes the plist data into v.")
+		j.file.Func().Params(Id("PlistCodec")).Id("Unmarshal").Params(Id("data").Index().Byte(), Id("v").Interface()).Error().Block(
+			c.unmarshal(c.path)...,
+		)
+
+		j.file.Comment("DefaultCodec is the Codec used by MarshalCommand, UnmarshalResponse,")
+		j.file.Comment("and ResponseDecoders without an Unmarshal function.")
+		j.file.Var().Id("DefaultCodec").Id("Codec").Op("=").Id("PlistCodec").Values()
+	} else {
+		j.file.Comment("DefaultCodec is the Codec used by MarshalCommand, UnmarshalResponse,")
+		j.file.Comment("and ResponseDecoders without an Unmarshal function. It must be set.")
+		j.file.Var().Id("DefaultCodec").Id("Codec")
+	}
+
+	j.file.Comment("MarshalCommand encodes cmd (e.g. a *InstallApplicationCommand) using DefaultCodec.")
+	j.file.Func().Id("MarshalCommand").Params(Id("cmd").Id("GenericCommander")).Params(Index().Byte(), Error()).Block(
+		If(Id("DefaultCodec").Op("==").Nil()).Block(
+			Return(Nil(), Id("ErrNoCodec")),
+		),
+		Return(Id("DefaultCodec").Dot("Marshal").Call(Id("cmd"))),
+	)
+
+	if j.noResponses {
+		return
+	}
+
+	j.file.Comment("UnmarshalResponse decodes the response in data using DefaultCodec into a")
+	j.file.Comment("GenericResponse. Responses only carry the CommandUUID of their command, so")
+	j.file.Comment("use a ResponseDecoder or UnmarshalResponseFor to decode typed responses.")
+	j.file.Func().Id("UnmarshalResponse").Params(Id("data").Index().Byte()).Params(Op("*").Id("GenericResponse"), Error()).Block(
+		If(Id("DefaultCodec").Op("==").Nil()).Block(
+			Return(Nil(), Id("ErrNoCodec")),
+		),
+		Id("resp").Op(":=").New(Id("GenericResponse")),
+		If(Id("err").Op(":=").Id("DefaultCodec").Dot("Unmarshal").Call(Id("data"), Id("resp")), Id("err").Op("!=").Nil()).Block(
+			Return(Nil(), Id("err")),
+		),
+		Return(Id("resp"), Nil()),
+	)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	. "github.com/dave/jennifer/jen"
+)
+
+// insertResponseDecoder generates a decoder for command responses which
+// finds the RequestType of a response by its CommandUUID.
+func insertResponseDecoder(j *jenBuilder) {
+	j.file.Comment("ErrUnknownRequestType is returned when a response can't be created for a RequestType.")
+	j.file.Var().Id("ErrUnknownRequestType").Op("=").Qual("errors", "New").Call(Lit("unknown request type"))
+
+	j.file.Comment("ErrNoLookup is returned when a ResponseDecoder needs to look up a RequestType")
+	j.file.Comment("but has no Lookup.")
+	j.file.Var().Id("ErrNoLookup").Op("=").Qual("errors", "New").Call(Lit("no request type lookup"))
+
+	j.file.Comment("RequestTypeLookup finds the RequestType of a previously sent command by its CommandUUID.")
+	j.file.Type().Id("RequestTypeLookup").Interface(
+		Id("RequestTypeFor").Params(Id("uuid").String()).Params(String(), Error()),
+	)
+
+	j.file.Comment("ResponseDecoder decodes command responses into their RequestType-specific")
+	j.file.Comment("type. Device responses only carry the CommandUUID so the RequestType is")
+	j.file.Comment("found using Lookup.")
+	j.file.Type().Id("ResponseDecoder").Struct(
+		Id("Lookup").Id("RequestTypeLookup"),
+		Comment("Unmarshal decodes the plist data into v (e.g. plist.Unmarshal)."),
+		Comment("DefaultCodec is used if nil."),
+		Id("Unmarshal").Func().Params(Id("data").Index().Byte(), Id("v").Interface()).Error(),
+	)
+
+	j.file.Func().Params(Id("d").Op("*").Id("ResponseDecoder")).Id("unmarshal").Params(
+		Id("data").Index().Byte(),
+		Id("v").Interface(),
+	).Error().Block(
+		If(Id("d").Dot("Unmarshal").Op("!=").Nil()).Block(
+			Return(Id("d").Dot("Unmarshal").Call(Id("data"), Id("v"))),
+		).Else().If(Id("DefaultCodec").Op("==").Nil()).Block(
+			Return(Id("ErrNoCodec")),
+		),
+		Return(Id("DefaultCodec").Dot("Unmarshal").Call(Id("data"), Id("v"))),
+	)
+
+	j.file.Comment("Decode decodes the plist response in data. It returns the typed response")
+	j.file.Comment("(e.g. *InstallApplicationResponse) and its GenericResponse. Idle responses")
+	j.file.Comment("are not for any command: only the GenericResponse is returned.")
+	j.file.Comment("")
+	j.file.Comment("data is unmarshaled twice: first into a GenericResponse to find the")
+	j.file.Comment("CommandUUID (and thus the RequestType), then into the typed response.")
+	j.file.Func().Params(Id("d").Op("*").Id("ResponseDecoder")).Id("Decode").Params(
+		Id("data").Index().Byte(),
+	).Params(Interface(), Op("*").Id("GenericResponse"), Error()).Block(
+		Id("generic").Op(":=").New(Id("GenericResponse")),
+		If(Id("err").Op(":=").Id("d").Dot("unmarshal").Call(Id("data"), Id("generic")), Id("err").Op("!=").Nil()).Block(
+			Return(Nil(), Nil(), Qual("fmt", "Errorf").Call(Lit("decoding generic response: %w"), Id("err"))),
+		),
+		If(Id("generic").Dot("Status").Op("==").Id("StatusIdle")).Block(
+			Return(Nil(), Id("generic"), Nil()),
+		),
+		If(Id("d").Dot("Lookup").Op("==").Nil()).Block(
+			Return(Nil(), Id("generic"), Id("ErrNoLookup")),
+		),
+		List(Id("requestType"), Id("err")).Op(":=").Id("d").Dot("Lookup").Dot("RequestTypeFor").Call(Id("generic").Dot("CommandUUID")),
+		If(Id("err").Op("!=").Nil()).Block(
+			Return(Nil(), Id("generic"), Qual("fmt", "Errorf").Call(Lit("looking up request type for command %s: %w"), Id("generic").Dot("CommandUUID"), Id("err"))),
+		),
+		Id("resp").Op(":=").Id("NewResponse").Call(Id("requestType")),
+		If(Id("resp").Op("==").Nil()).Block(
+			Return(Nil(), Id("generic"), Qual("fmt", "Errorf").Call(Lit("%w: %s"), Id("ErrUnknownRequestType"), Id("requestType"))),
+		),
+		If(Id("err").Op("=").Id("d").Dot("unmarshal").Call(Id("data"), Id("resp")), Id("err").Op("!=").Nil()).Block(
+			Return(Nil(), Id("generic"), Qual("fmt", "Errorf").Call(Lit("decoding %s response: %w"), Id("requestType"), Id("err"))),
+		),
+		If(List(Id("gr"), Id("ok")).Op(":=").Id("resp").Assert(Id("GenericResponser")), Id("ok")).Block(
+			Id("generic").Op("=").Id("gr").Dot("GetGenericResponse").Call(),
+		),
+		Return(Id("resp"), Id("generic"), Nil()),
+	)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
+)
+
+// ErrorDefinition is a known error code of an MDM error domain.
+type ErrorDefinition struct {
+	Code        int    `yaml:"code"`
+	Name        string `yaml:"name,omitempty"`
+	Description string `yaml:"description"`
+}
+
+// ErrorDomain represents the errors of an error domain defined in the
+// Apple Device Management YAML.
+type ErrorDomain struct {
+	Domain      string            `yaml:"domain"`
+	Description string            `yaml:"description,omitempty"`
+	Errors      []ErrorDefinition `yaml:"errors"`
+}
+
+// loadErrors reads the error domain YAML at path (a file or directory),
+// applying any overlay patches. Errors of the same domain in multiple files
+// are combined and only the first definition of a code is kept. Also
+// returned is a description of the source for the generated header.
+func loadErrors(src *admgen.Source, path string, overlay *admgen.Overlay) ([]ErrorDomain, string, error) {
+	var domains []ErrorDomain
+	index := make(map[string]int)
+	var hashes []string
+	err := src.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error accessing path %s: %w", p, err)
+		}
+		if d.IsDir() || filepath.Ext(p) != ".yaml" {
+			return nil
+		}
+		data, err := src.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", p, err)
+		}
+		rel, _ := filepath.Rel(path, p)
+		hashes = append(hashes, filepath.ToSlash(rel)+" "+admgen.ContentHash(data))
+		ed := ErrorDomain{}
+		if err = overlay.Decode(bytes.NewReader(data), p, &ed); err != nil {
+			return fmt.Errorf("decoding yaml in %s: %w", p, err)
+		}
+		if ed.Domain == "" {
+			return nil
+		}
+		i, ok := index[ed.Domain]
+		if !ok {
+			i = len(domains)
+			index[ed.Domain] = i
+			domains = append(domains, ErrorDomain{Domain: ed.Domain, Description: ed.Description})
+		}
+		for _, e := range ed.Errors {
+			if hasErrorCode(domains[i].Errors, e.Code) {
+				fmt.Fprintf(os.Stderr, "warning: %s: duplicate %s error code %d ignored\n", p, ed.Domain, e.Code)
+				continue
+			}
+			domains[i].Errors = append(domains[i].Errors, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
+	return domains, fmt.Sprintf("%s (%d files, %s)", filepath.Base(path), len(hashes), admgen.ContentHash([]byte(strings.Join(hashes, "\n")))), nil
+}
+
+// hasErrorCode reports whether code is defined in errors.
+func hasErrorCode(errors []ErrorDefinition, code int) bool {
+	for _, e := range errors {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// errorVarName returns the name of the sentinel error for code e of domain.
+func errorVarName(domain string, e ErrorDefinition) string {
+	name := e.Name
+	if name == "" {
+		name = strconv.Itoa(e.Code)
+	}
+	return "Err" + admgen.FieldName(strings.TrimSuffix(domain, "ErrorDomain")) + admgen.FieldName(name)
+}
+
+// errorVarNames returns the names of the sentinel errors of ed. Names that
+// would be the same for multiple errors are qualified by their code.
+func errorVarNames(ed ErrorDomain) []string {
+	names := make([]string, len(ed.Errors))
+	count := make(map[string]int)
+	for i, e := range ed.Errors {
+		names[i] = errorVarName(ed.Domain, e)
+		count[names[i]]++
+	}
+	for i, e := range ed.Errors {
+		if count[names[i]] > 1 && e.Name != "" {
+			names[i] += strconv.Itoa(e.Code)
+		}
+	}
+	return names
+}
+
+// insertErrors generates the table of known MDM errors and sentinel errors
+// for each of them.
+func (j *jenBuilder) insertErrors() {
+	j.file.Comment("ErrorCode identifies an MDM error by its domain and code.")
+	j.file.Comment("ErrorCodes are comparable and used as sentinel errors with errors.Is.")
+	j.file.Type().Id("ErrorCode").Struct(
+		Id("Domain").String(),
+		Id("Code").Int(),
+	)
+
+	j.file.Comment("Error adapts a standard Go error for ErrorCode.")
+	j.file.Func().Params(Id("c").Id("ErrorCode")).Id("Error").Params().String().Block(
+		If(List(Id("d"), Id("ok")).Op(":=").Id("Errors").Index(Id("c")), Id("ok")).Block(
+			Return(Qual("fmt", "Sprintf").Call(Lit("%s (%s, %d)"), Id("d").Dot("Description"), Id("c").Dot("Domain"), Id("c").Dot("Code"))),
+		),
+		Return(Qual("fmt", "Sprintf").Call(Lit("unknown error (%s, %d)"), Id("c").Dot("Domain"), Id("c").Dot("Code"))),
+	)
+
+	j.file.Comment("ErrorDescription describes a known MDM error.")
+	j.file.Type().Id("ErrorDescription").Struct(
+		Id("Name").String(),
+		Id("Description").String(),
+	)
+
+	var vars []Code
+	table := Dict{}
+	for _, ed := range j.errors {
+		names := errorVarNames(ed)
+		for i, e := range ed.Errors {
+			name := names[i]
+			comment := fmt.Sprintf("%s is %s error %d", name, ed.Domain, e.Code)
+			if e.Description != "" {
+				comment += ": " + e.Description
+			}
+			vars = append(vars, Comment(comment))
+			vars = append(vars, Id(name).Op("=").Id("ErrorCode").Values(Dict{
+				Id("Domain"): Lit(ed.Domain),
+				Id("Code"):   Lit(e.Code),
+			}))
+			d := Dict{Id("Description"): Lit(e.Description)}
+			if e.Name != "" {
+				d[Id("Name")] = Lit(e.Name)
+			}
+			table[Id(name)] = Values(d)
+		}
+	}
+	if len(vars) > 0 {
+		j.file.Var().Defs(vars...)
+	}
+
+	j.file.Comment("Errors is the table of known MDM errors.")
+	j.file.Var().Id("Errors").Op("=").Map(Id("ErrorCode")).Id("ErrorDescription").Values(table)
+
+	j.file.Comment("Code returns the ErrorCode of i.")
+	j.file.Func().Params(Id("i").Id("ErrorChainItem")).Id("Code").Params().Id("ErrorCode").Block(
+		Return(Id("ErrorCode").Values(Dict{
+			Id("Domain"): Id("i").Dot("ErrorDomain"),
+			Id("Code"):   Id("i").Dot("ErrorCode"),
+		})),
+	)
+
+	j.file.Comment("Is reports whether any item in the error chain matches target,")
+	j.file.Comment("an ErrorCode. This allows using errors.Is with the sentinel errors.")
+	j.file.Func().Params(
+		Id("ec").Op("*").Id("ErrorChain"),
+	).Id("Is").Params(Id("target").Error()).Bool().Block(
+		List(Id("c"), Id("ok")).Op(":=").Id("target").Assert(Id("ErrorCode")),
+		If(Op("!").Id("ok").Op("||").Id("ec").Op("==").Nil()).Block(
+			Return(False()),
+		),
+		For(List(Id("_"), Id("item")).Op(":=").Range().Op("*").Id("ec")).Block(
+			If(Id("item").Dot("Code").Call().Op("==").Id("c")).Block(
+				Return(True()),
+			),
+		),
+		Return(False()),
+	)
+}
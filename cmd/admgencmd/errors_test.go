@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/admgen"
+)
+
+func TestErrorVarNames(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		errors []ErrorDefinition
+		want   []string
+	}{
+		{
+			"distinct",
+			[]ErrorDefinition{{Code: 1, Name: "Foo"}, {Code: 2, Name: "bar baz"}, {Code: 3}},
+			[]string{"ErrTestFoo", "ErrTestBarBaz", "ErrTest3"},
+		},
+		{
+			"same name",
+			[]ErrorDefinition{{Code: 1, Name: "Foo"}, {Code: 2, Name: "Foo"}, {Code: 3, Name: "Bar"}},
+			[]string{"ErrTestFoo1", "ErrTestFoo2", "ErrTestBar"},
+		},
+		{
+			"name is a code",
+			[]ErrorDefinition{{Code: 1, Name: "2"}, {Code: 2}},
+			[]string{"ErrTest21", "ErrTest2"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			have := errorVarNames(ErrorDomain{Domain: "TestErrorDomain", Errors: tc.errors})
+			if !reflect.DeepEqual(have, tc.want) {
+				t.Errorf("have %v, want %v", have, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.yaml": "domain: TestErrorDomain\nerrors:\n- code: 1\n  name: One\n- code: 2\n  description: two\n",
+		"b.yaml": "domain: TestErrorDomain\nerrors:\n- code: 2\n  description: duplicate\n- code: 3\n",
+		"c.yaml": "domain: OtherErrorDomain\nerrors:\n- code: 1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overlay, err := admgen.ReadOverlay(strings.NewReader(`patches:
+- name: name two
+  file: "*a.yaml"
+  path: errors.2
+  set:
+    name: Two
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	domains, _, err := loadErrors(nil, dir, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ErrorDomain{
+		{Domain: "OtherErrorDomain", Errors: []ErrorDefinition{{Code: 1}}},
+		{Domain: "TestErrorDomain", Errors: []ErrorDefinition{
+			{Code: 1, Name: "One"},
+			{Code: 2, Name: "Two", Description: "two"},
+			{Code: 3},
+		}},
+	}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("have %+v, want %+v", domains, want)
+	}
+	if applied := overlay.Applied(); len(applied) != 1 {
+		t.Errorf("overlay not applied")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	. "github.com/dave/jennifer/jen"
+)
+
+// insertResponseHandler generates the ResponseHandler interface with a
+// method for each walked command response and a function to dispatch
+// responses to it. At least one response must have been walked.
+func (j *jenBuilder) insertResponseHandler() {
+	ctx := Id("ctx").Qual("context", "Context")
+
+	methods := []Code{
+		Comment("HandleIdle handles Idle responses, which are not for any command."),
+		Id("HandleIdle").Params(ctx, Id("resp").Op("*").Id("GenericResponse")).Error(),
+	}
+	for _, name := range j.responses {
+		methods = append(methods, Id("Handle"+name).Params(ctx, Id("resp").Op("*").Id(name+"Response")).Error())
+	}
+	j.file.Comment("ResponseHandler handles the typed responses of MDM commands.")
+	j.file.Type().Id("ResponseHandler").Interface(methods...)
+
+	j.file.Comment("ErrUnimplementedResponse is returned by UnimplementedResponseHandler.")
+	j.file.Var().Id("ErrUnimplementedResponse").Op("=").Qual("errors", "New").Call(Lit("response handler not implemented"))
+
+	j.file.Comment("UnimplementedResponseHandler can be embedded in ResponseHandlers to only")
+	j.file.Comment("implement handlers for some commands. Its methods return ErrUnimplementedResponse")
+	j.file.Comment("except HandleIdle which does nothing.")
+	j.file.Type().Id("UnimplementedResponseHandler").Struct()
+
+	j.file.Comment("HandleIdle does nothing.")
+	j.file.Func().Params(Id("UnimplementedResponseHandler")).Id("HandleIdle").Params(
+		Qual("context", "Context"), Op("*").Id("GenericResponse"),
+	).Error().Block(
+		Return(Nil()),
+	)
+
+	for _, name := range j.responses {
+		j.file.Comment("Handle" + name + " returns ErrUnimplementedResponse.")
+		j.file.Func().Params(Id("UnimplementedResponseHandler")).Id("Handle"+name).Params(
+			Qual("context", "Context"), Op("*").Id(name+"Response"),
+		).Error().Block(
+			Return(Qual("fmt", "Errorf").Call(Lit("%w: %s"), Id("ErrUnimplementedResponse"), Id(name+"RequestType"))),
+		)
+	}
+
+	cases := []Code{
+		Case(Nil()).Block(
+			Return(Id("h").Dot("HandleIdle").Call(Id("ctx"), Id("generic"))),
+		),
+	}
+	for _, name := range j.responses {
+		cases = append(cases, Case(Op("*").Id(name+"Response")).Block(
+			Return(Id("h").Dot("Handle"+name).Call(Id("ctx"), Id("r"))),
+		))
+	}
+	j.file.Comment("DispatchResponse decodes the response in data using d and calls the")
+	j.file.Comment("method of h for its type.")
+	j.file.Func().Id("DispatchResponse").Params(
+		ctx,
+		Id("d").Op("*").Id("ResponseDecoder"),
+		Id("h").Id("ResponseHandler"),
+		Id("data").Index().Byte(),
+	).Error().Block(
+		List(Id("resp"), Id("generic"), Id("err")).Op(":=").Id("d").Dot("Decode").Call(Id("data")),
+		If(Id("err").Op("!=").Nil()).Block(
+			Return(Id("err")),
+		),
+		Switch(Id("r").Op(":=").Id("resp").Assert(Type())).Block(cases...),
+		Return(Qual("fmt", "Errorf").Call(Lit("%w: %T"), Id("ErrUnknownRequestType"), Id("resp"))),
+	)
+}
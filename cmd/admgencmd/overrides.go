@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override customizes the generated Go code for a single schema key.
+type Override struct {
+	// force this Go type for the key. may be package qualified
+	// (e.g. "encoding/json.RawMessage") and a pointer or slice
+	// (e.g. "*net/url.URL"), which is then used as-is.
+	Type string `yaml:"type,omitempty"`
+	// override the Go field name. the plist key is unchanged.
+	Name string `yaml:"name,omitempty"`
+	// embed the field type into the parent struct.
+	Embed bool `yaml:"embed,omitempty"`
+	// include the schema content on the field comment.
+	Comment bool `yaml:"comment,omitempty"`
+	// do not generate this key at all.
+	Skip bool `yaml:"skip,omitempty"`
+}
+
+// Overrides is a set of key overrides keyed by schema path.
+// Schema paths are dot-separated and start with the command
+// RequestType and key section. For example:
+// "InstallApplication.payloadkeys.Options.PurchaseMethod".
+type Overrides struct {
+	Keys map[string]Override
+
+	used map[string]bool
+}
+
+// LoadOverrides reads an overrides YAML file from path.
+func LoadOverrides(path string) (*Overrides, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	o := &Overrides{used: make(map[string]bool)}
+	if err = yaml.NewDecoder(f).Decode(&o.Keys); err != nil {
+		return nil, fmt.Errorf("decoding overrides: %w", err)
+	}
+	return o, nil
+}
+
+// Apply returns a copy of keys with any overrides under path applied.
+func (o *Overrides) Apply(path string, keys []Key) []Key {
+	if o == nil || len(keys) < 1 {
+		return keys
+	}
+	var ret []Key
+	for _, k := range keys {
+		keyPath := path + "." + k.Key
+		if ov, ok := o.Keys[keyPath]; ok {
+			o.used[keyPath] = true
+			if ov.Skip {
+				continue
+			}
+			if ov.Type != "" {
+				k.Type = ov.Type
+				k.forceRawType = true
+			}
+			k.fieldName = ov.Name
+			k.embeddedStruct = ov.Embed
+			k.includeContent = ov.Comment
+		}
+		k.SubKeys = o.Apply(keyPath, k.SubKeys)
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+// Unused returns the sorted schema paths of overrides that were never applied.
+func (o *Overrides) Unused() []string {
+	if o == nil {
+		return nil
+	}
+	var paths []string
+	for path := range o.Keys {
+		if !o.used[path] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRawType(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"PurchaseMethod", "PurchaseMethod"},
+		{"encoding/json.RawMessage", "json.RawMessage"},
+		{"*encoding/json.RawMessage", "*json.RawMessage"},
+		{"[]encoding/json.RawMessage", "[]json.RawMessage"},
+		{"[]*net/url.URL", "[]*url.URL"},
+		{"*[]string", "*[]string"},
+	} {
+		if have := fmt.Sprintf("%#v", rawType(tc.in)); have != tc.want {
+			t.Errorf("%q: have %q, want %q", tc.in, have, tc.want)
+		}
+	}
+}
+
+func TestOverridesApply(t *testing.T) {
+	o := &Overrides{
+		Keys: map[string]Override{
+			"Cmd.payloadkeys.A":         {Name: "Renamed"},
+			"Cmd.payloadkeys.B":         {Skip: true},
+			"Cmd.payloadkeys.C.D":       {Type: "encoding/json.Number", Comment: true},
+			"Cmd.payloadkeys.C.E":       {Type: "time.Time", Embed: true},
+			"Cmd.payloadkeys.Missing":   {Skip: true},
+			"Other.payloadkeys.A":       {Skip: true},
+			"Cmd.responsekeys.Response": {Name: "R"},
+		},
+		used: make(map[string]bool),
+	}
+	keys := o.Apply("Cmd.payloadkeys", []Key{
+		{Key: "A", Type: "<string>"},
+		{Key: "B", Type: "<string>"},
+		{Key: "C", Type: "<dictionary>", SubKeys: []Key{
+			{Key: "D", Type: "<integer>"},
+			{Key: "E", Type: "<date>"},
+		}},
+	})
+	want := []Key{
+		{Key: "A", Type: "<string>", fieldName: "Renamed"},
+		{Key: "C", Type: "<dictionary>", SubKeys: []Key{
+			{Key: "D", Type: "encoding/json.Number", forceRawType: true, includeContent: true},
+			{Key: "E", Type: "time.Time", forceRawType: true, embeddedStruct: true},
+		}},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("have %+v, want %+v", keys, want)
+	}
+	wantUnused := []string{"Cmd.payloadkeys.Missing", "Cmd.responsekeys.Response", "Other.payloadkeys.A"}
+	if have := o.Unused(); !reflect.DeepEqual(have, wantUnused) {
+		t.Errorf("unused: have %v, want %v", have, wantUnused)
+	}
+}
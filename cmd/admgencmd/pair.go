@@ -0,0 +1,84 @@
+package main
+
+import (
+	. "github.com/dave/jennifer/jen"
+)
+
+// insertPairs generates the generic types and functions linking commands
+// to their response types.
+func insertPairs(j *jenBuilder) {
+	j.file.Comment("CommandWithResponse is a command whose response type is R. For example")
+	j.file.Comment("*InstallApplicationCommand is a CommandWithResponse[*InstallApplicationResponse].")
+	j.file.Type().Id("CommandWithResponse").Types(Id("R").Id("GenericResponser")).Interface(
+		Id("Command"),
+		Id("NewResponse").Params().Id("R"),
+	)
+
+	j.file.Comment("ResponseFor creates a new response of the type for the command type C,")
+	j.file.Comment("e.g. ResponseFor[*InstallApplicationCommand]() is an *InstallApplicationResponse.")
+	j.file.Comment("Go versions before 1.21 can't infer R from C, so both must be given:")
+	j.file.Comment("ResponseFor[*InstallApplicationCommand, *InstallApplicationResponse]().")
+	j.file.Func().Id("ResponseFor").Types(
+		Id("C").Id("CommandWithResponse").Types(Id("R")),
+		Id("R").Id("GenericResponser"),
+	).Params().Id("R").Block(
+		Comment("NewResponse does not use its (nil) receiver"),
+		Var().Id("cmd").Id("C"),
+		Return(Id("cmd").Dot("NewResponse").Call()),
+	)
+
+	j.file.Comment("UnmarshalResponseFor decodes the response in data using DefaultCodec")
+	j.file.Comment("into a new response of the type for cmd.")
+	j.file.Func().Id("UnmarshalResponseFor").Types(Id("R").Id("GenericResponser")).Params(
+		Id("cmd").Id("CommandWithResponse").Types(Id("R")),
+		Id("data").Index().Byte(),
+	).Params(Id("R"), Error()).Block(
+		Id("resp").Op(":=").Id("cmd").Dot("NewResponse").Call(),
+		If(Id("DefaultCodec").Op("==").Nil()).Block(
+			Return(Id("resp"), Id("ErrNoCodec")),
+		),
+		Return(Id("resp"), Id("DefaultCodec").Dot("Unmarshal").Call(Id("data"), Id("resp"))),
+	)
+
+	j.file.Comment("Pair links the command type C to its response type R. Unlike commands,")
+	j.file.Comment("both C and R are inferred from a Pair argument of generic functions.")
+	j.file.Type().Id("Pair").Types(
+		Id("C").Id("CommandWithResponse").Types(Id("R")),
+		Id("R").Id("GenericResponser"),
+	).Struct(
+		Id("RequestType").String(),
+		Id("NewCommand").Func().Params(Id("uuid").String()).Id("C"),
+		Id("NewResponse").Func().Params().Id("R"),
+	)
+
+	j.file.Comment("UnmarshalResponse decodes the response in data using DefaultCodec into")
+	j.file.Comment("a new response of type R.")
+	j.file.Func().Params(Id("p").Id("Pair").Types(Id("C"), Id("R"))).Id("UnmarshalResponse").Params(
+		Id("data").Index().Byte(),
+	).Params(Id("R"), Error()).Block(
+		Id("resp").Op(":=").Id("p").Dot("NewResponse").Call(),
+		If(Id("DefaultCodec").Op("==").Nil()).Block(
+			Return(Id("resp"), Id("ErrNoCodec")),
+		),
+		Return(Id("resp"), Id("DefaultCodec").Dot("Unmarshal").Call(Id("data"), Id("resp"))),
+	)
+}
+
+// insertPair links the command name to its response type.
+func insertPair(name string, j *jenBuilder) {
+	j.file.Comment("NewResponse creates a new response for c.")
+	j.file.Func().Params(Id("c").Op("*").Id(name + "Command")).Id("NewResponse").Params().Op("*").Id(name + "Response").Block(
+		Return(New(Id(name + "Response"))),
+	)
+
+	j.file.Comment(name + "Pair links " + name + "Command to " + name + "Response.")
+	j.file.Var().Id(name+"Pair").Op("=").Id("Pair").Types(Op("*").Id(name+"Command"), Op("*").Id(name+"Response")).Values(Dict{
+		Id("RequestType"): Id(name + "RequestType"),
+		Id("NewCommand"): Func().Params(Id("uuid").String()).Op("*").Id(name + "Command").Block(
+			Return(Id("New" + name + "Command").Call(Id("uuid"))),
+		),
+		Id("NewResponse"): Func().Params().Op("*").Id(name + "Response").Block(
+			Return(New(Id(name + "Response"))),
+		),
+	})
+}
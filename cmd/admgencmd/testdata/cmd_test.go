@@ -0,0 +1,246 @@
+package mdm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// jsonCodec stands in for a plist library.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func init() {
+	DefaultCodec = jsonCodec{}
+}
+
+func TestResponseErrorIs(t *testing.T) {
+	resp := &InstallApplicationResponse{}
+	resp.CommandUUID = "uuid"
+	resp.Status = StatusCommandFormatError
+	err := resp.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, tc := range []struct {
+		target error
+		want   bool
+	}{
+		{ErrStatusCommandFormatError, true},
+		{ErrStatusError, false},
+		{&ResponseError{RequestType: InstallApplicationRequestType}, true},
+		{&ResponseError{RequestType: InstallApplicationRequestType, Status: StatusError}, false},
+		{&ResponseError{CommandUUID: "other"}, false},
+	} {
+		if have := errors.Is(err, tc.target); have != tc.want {
+			t.Errorf("errors.Is(%v): have %v, want %v", tc.target, have, tc.want)
+		}
+	}
+
+	resp.Status = StatusAcknowledged
+	if err := resp.Validate(); err != nil {
+		t.Errorf("acknowledged: %v", err)
+	}
+}
+
+func TestErrorChainIs(t *testing.T) {
+	resp := &InstallApplicationResponse{}
+	resp.Status = StatusError
+	resp.ErrorChain = &ErrorChain{{ErrorDomain: "MCInstallationErrorDomain", ErrorCode: 4001}}
+	err := resp.Validate()
+	if !errors.Is(err, ErrMCInstallationCannotParseProfile) || !errors.Is(err, ErrStatusError) {
+		t.Errorf("errors.Is: %v", err)
+	}
+	if errors.Is(err, ErrMCInstallation4009) {
+		t.Errorf("errors.Is(ErrMCInstallation4009): %v", err)
+	}
+	if want := "MDM error for InstallApplication command  status Error: The profile could not be parsed. (MCInstallationErrorDomain, 4001)"; err.Error() != want {
+		t.Errorf("have %q, want %q", err.Error(), want)
+	}
+}
+
+type lookup map[string]string
+
+func (l lookup) RequestTypeFor(uuid string) (string, error) {
+	if rt, ok := l[uuid]; ok {
+		return rt, nil
+	}
+	return "", errors.New("unknown command")
+}
+
+func TestCommand(t *testing.T) {
+	for _, requestType := range []string{"Unknown", InstallApplicationRequestType, DeviceInformationRequestType} {
+		t.Run(requestType, func(t *testing.T) {
+			var cmd Command = NewGenericCommand(requestType, "a")
+			if requestType != "Unknown" {
+				cmd = NewCommand(requestType, "a").(Command)
+			}
+			if cmd.RequestType() != requestType || cmd.UUID() != "a" || cmd.RequiresNetworkTether() {
+				t.Errorf("have %q %q %v", cmd.RequestType(), cmd.UUID(), cmd.RequiresNetworkTether())
+			}
+			cmd.SetUUID("b")
+			if cmd.UUID() != "b" {
+				t.Errorf("SetUUID: have %q", cmd.UUID())
+			}
+			if cmd.Payload() == nil {
+				t.Error("no payload")
+			}
+		})
+	}
+
+	cmd := NewInstallApplicationCommand("a", InstallApplicationWithRequestRequiresNetworkTether(true))
+	if p, ok := cmd.Payload().(*InstallApplicationPayload); !ok || p != &cmd.Command {
+		t.Errorf("payload: have %#v", cmd.Payload())
+	}
+	if !cmd.RequiresNetworkTether() {
+		t.Error("expected network tether")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	install := NewInstallApplicationCommand("a",
+		InstallApplicationWithITunesStoreID(1),
+		InstallApplicationWithOptions(Options{PurchaseMethod: new(int)}),
+		InstallApplicationWithRequestRequiresNetworkTether(true),
+	)
+	want := &InstallApplicationCommand{
+		Command: InstallApplicationPayload{
+			ITunesStoreID:                new(int),
+			Options:                      &Options{PurchaseMethod: new(int)},
+			RequestType:                  InstallApplicationRequestType,
+			RequestRequiresNetworkTether: new(bool),
+		},
+		CommandUUID: "a",
+	}
+	*want.Command.ITunesStoreID = 1
+	*want.Command.RequestRequiresNetworkTether = true
+	if !reflect.DeepEqual(install, want) {
+		t.Errorf("have %+v, want %+v", install, want)
+	}
+
+	// each option allocates its own value
+	opt := DeviceInformationWithDeviceType("A")
+	a := NewDeviceInformationCommand("a", opt, DeviceInformationWithQueries([]string{"UDID"}))
+	b := NewDeviceInformationCommand("b", opt)
+	if a.Command.DeviceType == nil || *a.Command.DeviceType != "A" || a.Command.DeviceType == b.Command.DeviceType {
+		t.Errorf("DeviceType: have %v and %v", a.Command.DeviceType, b.Command.DeviceType)
+	}
+	if !reflect.DeepEqual(a.Command.Queries, []string{"UDID"}) || b.Command.Queries != nil {
+		t.Errorf("Queries: have %v and %v", a.Command.Queries, b.Command.Queries)
+	}
+
+	if cmd := NewDeviceInformationCommand("c"); cmd.Command.RequestType != DeviceInformationRequestType || cmd.Command.DeviceType != nil {
+		t.Errorf("without options: have %+v", cmd)
+	}
+}
+
+// sendAndAwait is the generic "send and await" of the README with C and R
+// inferred from p.
+func sendAndAwait[C CommandWithResponse[R], R GenericResponser](p Pair[C, R], uuid string, data []byte) (C, R, error) {
+	cmd := p.NewCommand(uuid)
+	resp, err := p.UnmarshalResponse(data)
+	return cmd, resp, err
+}
+
+func TestPair(t *testing.T) {
+	var resp *InstallApplicationResponse = ResponseFor[*InstallApplicationCommand]()
+	if resp == nil {
+		t.Fatal("ResponseFor: nil response")
+	}
+
+	data := []byte(`{"CommandUUID": "a", "Status": "Acknowledged", "Identifier": "com.example.app"}`)
+	if resp, err := UnmarshalResponseFor[*InstallApplicationResponse](NewInstallApplicationCommand("a"), data); err != nil || resp.Identifier == nil || *resp.Identifier != "com.example.app" {
+		t.Errorf("UnmarshalResponseFor: have %+v %v", resp, err)
+	}
+
+	if InstallApplicationPair.RequestType != InstallApplicationRequestType {
+		t.Errorf("RequestType: have %q", InstallApplicationPair.RequestType)
+	}
+	if resp := InstallApplicationPair.NewResponse(); resp == nil || resp == InstallApplicationPair.NewResponse() {
+		t.Errorf("NewResponse: have %p", resp)
+	}
+	cmd, resp, err := sendAndAwait(InstallApplicationPair, "a", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.UUID() != "a" || cmd.RequestType() != InstallApplicationRequestType {
+		t.Errorf("command: have %+v", cmd)
+	}
+	if resp.CommandUUID != "a" || resp.Identifier == nil || *resp.Identifier != "com.example.app" {
+		t.Errorf("response: have %+v", resp)
+	}
+
+	codec := DefaultCodec
+	defer func() { DefaultCodec = codec }()
+	DefaultCodec = nil
+	if _, err := DeviceInformationPair.UnmarshalResponse(data); !errors.Is(err, ErrNoCodec) {
+		t.Errorf("without codec: have %v, want %v", err, ErrNoCodec)
+	}
+}
+
+func TestResponseDecoder(t *testing.T) {
+	idle := []byte(`{"Status": "Idle"}`)
+	install := []byte(`{"CommandUUID": "a", "Status": "Acknowledged", "Identifier": "com.example.app"}`)
+
+	var d ResponseDecoder
+	resp, generic, err := d.Decode(idle)
+	if err != nil || resp != nil || generic.Status != StatusIdle {
+		t.Errorf("idle without lookup: %v %v %v", resp, generic, err)
+	}
+	if _, _, err = d.Decode(install); !errors.Is(err, ErrNoLookup) {
+		t.Errorf("without lookup: have %v, want %v", err, ErrNoLookup)
+	}
+
+	d.Lookup = lookup{"a": InstallApplicationRequestType, "b": "Unknown"}
+	resp, generic, err = d.Decode(install)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := resp.(*InstallApplicationResponse)
+	if !ok || r.Identifier == nil || *r.Identifier != "com.example.app" || generic != &r.GenericResponse {
+		t.Errorf("have %#v %#v", resp, generic)
+	}
+
+	if _, _, err = d.Decode([]byte(`{"CommandUUID": "b", "Status": "Acknowledged"}`)); !errors.Is(err, ErrUnknownRequestType) {
+		t.Errorf("have %v, want %v", err, ErrUnknownRequestType)
+	}
+	if _, _, err = d.Decode([]byte(`{"CommandUUID": "c", "Status": "Acknowledged"}`)); err == nil {
+		t.Error("expected lookup error")
+	}
+}
+
+type handler struct {
+	UnimplementedResponseHandler
+	idle    int
+	devInfo *DeviceInformationResponse
+}
+
+func (h *handler) HandleIdle(ctx context.Context, resp *GenericResponse) error {
+	h.idle++
+	return nil
+}
+
+func (h *handler) HandleDeviceInformation(ctx context.Context, resp *DeviceInformationResponse) error {
+	h.devInfo = resp
+	return nil
+}
+
+func TestDispatchResponse(t *testing.T) {
+	d := &ResponseDecoder{Lookup: lookup{"a": InstallApplicationRequestType, "b": DeviceInformationRequestType}}
+	h := &handler{}
+	ctx := context.Background()
+	if err := DispatchResponse(ctx, d, h, []byte(`{"Status": "Idle"}`)); err != nil || h.idle != 1 {
+		t.Errorf("idle: %v %d", err, h.idle)
+	}
+	if err := DispatchResponse(ctx, d, h, []byte(`{"CommandUUID": "b", "Status": "Acknowledged"}`)); err != nil || h.devInfo == nil || h.devInfo.CommandUUID != "b" {
+		t.Errorf("device information: %v %v", err, h.devInfo)
+	}
+	err := DispatchResponse(ctx, d, h, []byte(`{"CommandUUID": "a", "Status": "Acknowledged"}`))
+	if !errors.Is(err, ErrUnimplementedResponse) {
+		t.Errorf("have %v, want %v", err, ErrUnimplementedResponse)
+	}
+}
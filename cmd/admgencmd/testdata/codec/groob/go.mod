@@ -0,0 +1,3 @@
+module github.com/groob/plist
+
+go 1.19
@@ -0,0 +1,9 @@
+// Package plist stands in for github.com/groob/plist with the same API for
+// the generated PlistCodec, encoding with encoding/json.
+package plist
+
+import "encoding/json"
+
+func Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
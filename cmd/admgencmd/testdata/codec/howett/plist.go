@@ -0,0 +1,11 @@
+// Package plist stands in for howett.net/plist with the same API for the
+// generated PlistCodec, encoding with encoding/json.
+package plist
+
+import "encoding/json"
+
+const XMLFormat = 1
+
+func Marshal(v interface{}, format int) ([]byte, error) { return json.Marshal(v) }
+
+func Unmarshal(data []byte, v interface{}) (int, error) { return XMLFormat, json.Unmarshal(data, v) }
@@ -0,0 +1,37 @@
+package mdm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlistCodec(t *testing.T) {
+	if _, ok := DefaultCodec.(PlistCodec); !ok {
+		t.Fatalf("DefaultCodec: have %T, want PlistCodec", DefaultCodec)
+	}
+	cmd := NewDeviceInformationCommand("uuid")
+	cmd.Command.Queries = []string{"UDID"}
+	data, err := MarshalCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := new(DeviceInformationCommand)
+	if err := DefaultCodec.Unmarshal(data, have); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, cmd) {
+		t.Errorf("command: have %+v, want %+v", have, cmd)
+	}
+
+	want := &GenericResponse{CommandUUID: "uuid", Status: StatusAcknowledged}
+	if data, err = (PlistCodec{}).Marshal(want); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := UnmarshalResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("response: have %+v, want %+v", resp, want)
+	}
+}
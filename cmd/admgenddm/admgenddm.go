@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
+)
+
+type DeclarationPayloadSchema struct {
+	DeclarationType string                 `yaml:"declarationtype"`
+	SupportedOS     map[string]SupportedOS `yaml:"supportedOS"`
+	Apply           string                 `yaml:"apply"`
+}
+
+// DeclarationSchema is a declarative device management declaration.
+type DeclarationSchema struct {
+	Title       string                   `yaml:"title"`
+	Payload     DeclarationPayloadSchema `yaml:"payload"`
+	PayloadKeys []admgen.SchemaKey       `yaml:"payloadkeys"`
+}
+
+// declaration is a declaration type to generate.
+type declaration struct {
+	name   string
+	schema *DeclarationSchema
+}
+
+// declSchema is the result of walking the declaration schema files.
+type declSchema struct {
+	decls []declaration
+
+	files int
+	hash  string
+}
+
+// typeName returns the Go type name of the declaration type t.
+// For example "com.apple.configuration.passcode.settings" is
+// "ConfigurationPasscodeSettings".
+func typeName(t string) string {
+	return admgen.FieldName(strings.TrimPrefix(t, "com.apple."))
+}
+
+// isConfiguration reports whether t is a configuration declaration type.
+func isConfiguration(t string) bool {
+	return strings.HasPrefix(t, "com.apple.configuration.")
+}
+
+// warnCombineTypes warns about keys with unknown combine types and
+// nested keys with combine types, which are not combined.
+func warnCombineTypes(path string, keys []admgen.SchemaKey) {
+	for _, k := range keys {
+		warnNestedCombineTypes(path, k.Key, k.SubKeys)
+		if k.CombineType != "" && !combineTypes[k.CombineType] {
+			admgen.Warnf("%s: key %s: unknown combinetype: %s", path, k.Key, k.CombineType)
+		} else if k.CombineType != "" && k.CombineType != "first" && !combinable(k) {
+			admgen.Warnf("%s: key %s: combinetype %s does not apply to %s", path, k.Key, k.CombineType, k.Type)
+		}
+	}
+}
+
+// warnNestedCombineTypes warns about combine types of the nested keys of
+// the key at path, which are ignored.
+func warnNestedCombineTypes(path, keyPath string, keys []admgen.SchemaKey) {
+	for _, k := range keys {
+		if k.CombineType != "" {
+			admgen.Warnf("%s: key %s.%s: combinetype of nested key ignored: %s", path, keyPath, k.Key, k.CombineType)
+		}
+		warnNestedCombineTypes(path, keyPath+"."+k.Key, k.SubKeys)
+	}
+}
+
+// walk reads the declaration schema files in dir.
+func walk(src *admgen.Source, dir string, overlay *admgen.Overlay) (*declSchema, error) {
+	s := &declSchema{}
+	var hashes []string
+	err := src.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error accessing path %s: %w", path, err)
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+
+		data, err := src.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		hashes = append(hashes, filepath.ToSlash(rel)+" "+admgen.ContentHash(data))
+
+		ds := &DeclarationSchema{}
+		if err = overlay.Decode(bytes.NewReader(data), path, ds); err != nil {
+			return fmt.Errorf("decoding yaml in %s: %w", path, err)
+		}
+
+		if ds.Payload.DeclarationType == "" {
+			return nil
+		}
+		if isConfiguration(ds.Payload.DeclarationType) {
+			warnCombineTypes(path, ds.PayloadKeys)
+		}
+		s.decls = append(s.decls, declaration{name: typeName(ds.Payload.DeclarationType), schema: ds})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(s.decls, func(i, j int) bool { return s.decls[i].name < s.decls[j].name })
+	s.files = len(hashes)
+	s.hash = admgen.ContentHash([]byte(strings.Join(hashes, "\n")))
+	return s, nil
+}
+
+func jenGo(pkgName string, s *declSchema, header []string, rev admgen.Revision, noCombine bool, w io.Writer) error {
+	file := jen.NewFile(pkgName)
+	for _, line := range header {
+		file.PackageComment(line)
+	}
+	types := admgen.NewJSONTypes(file)
+	enums := false
+	for _, d := range s.decls {
+		comment := d.name + " is the " + d.schema.Payload.DeclarationType + " declaration payload."
+		if d.schema.Title != "" {
+			comment = d.name + " is the " + d.schema.Title + " (" + d.schema.Payload.DeclarationType + ") declaration payload."
+		}
+		if err := types.Struct(d.name, comment, d.schema.PayloadKeys); err != nil {
+			return fmt.Errorf("%s: %w", d.schema.Payload.DeclarationType, err)
+		}
+		jenServerToken(file, d)
+		if noCombine || !isConfiguration(d.schema.Payload.DeclarationType) {
+			continue
+		}
+		jenCombine(file, types, d.name, d.schema.PayloadKeys)
+		for _, k := range d.schema.PayloadKeys {
+			enums = enums || (combinable(k) && strings.HasPrefix(k.CombineType, "enum-"))
+		}
+	}
+	if enums {
+		jenCombineEnumIndex(file)
+	}
+	admgen.JenServerToken(file, "declaration")
+	jenMetadata(file, s)
+	file.Comment("DeclarationSchemaVersion is the Apple Device Management schema revision these types were generated from.")
+	file.Const().Id("DeclarationSchemaVersion").Op("=").Lit(rev.Version())
+	return file.Render(w)
+}
+
+func main() {
+	g := admgen.NewGenerator("admgenddm")
+	flNoCombine := flag.Bool("no-combine", false, "do not generate Combine methods for configurations")
+	g.Parse()
+	g.Open(nil)
+
+	schema, err := walk(g.Source, g.Dir, g.Overlay)
+	if err != nil {
+		admgen.Fatalf(1, "walking directory: %v", err)
+	}
+	header := g.Header(g.Summary(schema.files, schema.hash))
+
+	output := new(bytes.Buffer)
+	if err = jenGo(*g.Pkg, schema, header, g.Revision, *flNoCombine, output); err != nil {
+		admgen.Fatalf(2, "rendering output: %v", err)
+	}
+	g.Finish(output.Bytes())
+}
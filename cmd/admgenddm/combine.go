@@ -0,0 +1,198 @@
+package main
+
+import (
+	"github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
+)
+
+// combineTypes are the supported values of the combinetype of a key.
+var combineTypes = map[string]bool{
+	"boolean-or":       true,
+	"boolean-and":      true,
+	"number-min":       true,
+	"number-max":       true,
+	"enum-lowest":      true,
+	"enum-highest":     true,
+	"set-union":        true,
+	"set-intersection": true,
+	"first":            true,
+}
+
+// combineOp returns the statements that combine the value of other into v
+// for key k. v and other are expressions of the (non-pointer) key type.
+// Nil is returned if only the first value is kept.
+func combineOp(k admgen.SchemaKey, v, other *jen.Statement, elemType *jen.Statement) []jen.Code {
+	switch k.CombineType {
+	case "boolean-or":
+		return []jen.Code{v.Clone().Op("=").Add(v.Clone()).Op("||").Add(other.Clone())}
+	case "boolean-and":
+		return []jen.Code{v.Clone().Op("=").Add(v.Clone()).Op("&&").Add(other.Clone())}
+	case "number-min", "number-max":
+		op := "<"
+		if k.CombineType == "number-max" {
+			op = ">"
+		}
+		return []jen.Code{jen.If(other.Clone().Op(op).Add(v.Clone())).Block(
+			v.Clone().Op("=").Add(other.Clone()),
+		)}
+	case "enum-lowest", "enum-highest":
+		op := "<"
+		if k.CombineType == "enum-highest" {
+			op = ">"
+		}
+		var values []jen.Code
+		for _, r := range k.RangeList {
+			values = append(values, jen.Lit(r))
+		}
+		list := jen.Index().String().Values(values...)
+		// values not in the rangelist are not ranked: the existing value is kept
+		return []jen.Code{jen.If(
+			jen.List(jen.Id("i"), jen.Id("j")).Op(":=").Id("combineEnumIndex").Call(other.Clone(), list).Op(",").Id("combineEnumIndex").Call(v.Clone(), list.Clone()),
+			jen.Id("i").Op(">=").Lit(0).Op("&&").Id("j").Op(">=").Lit(0).Op("&&").Id("i").Op(op).Id("j"),
+		).Block(
+			v.Clone().Op("=").Add(other.Clone()),
+		)}
+	case "set-union":
+		return []jen.Code{
+			jen.Id("u").Op(":=").Append(jen.Index().Add(elemType).Values(), v.Clone().Op("...")),
+			jen.For(jen.List(jen.Id("_"), jen.Id("w")).Op(":=").Range().Add(other.Clone())).Block(
+				jen.Id("found").Op(":=").False(),
+				jen.For(jen.List(jen.Id("_"), jen.Id("x")).Op(":=").Range().Id("u")).Block(
+					jen.If(jen.Id("x").Op("==").Id("w")).Block(
+						jen.Id("found").Op("=").True(),
+						jen.Break(),
+					),
+				),
+				jen.If(jen.Op("!").Id("found")).Block(
+					jen.Id("u").Op("=").Append(jen.Id("u"), jen.Id("w")),
+				),
+			),
+			v.Clone().Op("=").Id("u"),
+		}
+	case "set-intersection":
+		return []jen.Code{
+			jen.Id("u").Op(":=").Index().Add(elemType).Values(),
+			jen.For(jen.List(jen.Id("_"), jen.Id("x")).Op(":=").Range().Add(v.Clone())).Block(
+				jen.For(jen.List(jen.Id("_"), jen.Id("w")).Op(":=").Range().Add(other.Clone())).Block(
+					jen.If(jen.Id("x").Op("==").Id("w")).Block(
+						jen.Id("u").Op("=").Append(jen.Id("u"), jen.Id("x")),
+						jen.Break(),
+					),
+				),
+			),
+			v.Clone().Op("=").Id("u"),
+		}
+	}
+	return nil
+}
+
+// combinable reports whether key k can be combined with its combinetype.
+// Keys that can't be combined keep the first value.
+func combinable(k admgen.SchemaKey) bool {
+	switch k.CombineType {
+	case "boolean-or", "boolean-and":
+		return k.Type == "<boolean>"
+	case "number-min", "number-max":
+		return k.Type == "<integer>" || k.Type == "<real>"
+	case "enum-lowest", "enum-highest":
+		return k.Type == "<string>" && len(k.RangeList) > 0
+	case "set-union", "set-intersection":
+		if k.Type != "<array>" || len(k.SubKeys) < 1 {
+			return false
+		}
+		switch k.SubKeys[0].Type {
+		case "<string>", "<integer>", "<real>", "<boolean>":
+			return true
+		}
+	}
+	return false
+}
+
+// jenCombine generates a Combine method for the declaration payload type
+// name which combines multiple declarations of the same type using the
+// combinetype of each key.
+func jenCombine(file *jen.File, types *admgen.JSONTypes, name string, keys []admgen.SchemaKey) {
+	var body []jen.Code
+	for _, k := range keys {
+		field := admgen.FieldName(k.Key)
+		r := jen.Id("r").Dot(field)
+		o := jen.Id("o").Dot(field)
+		var ops []jen.Code
+		if combinable(k) {
+			var elemType *jen.Statement
+			if k.Type == "<array>" {
+				// set items are always scalars
+				elemType, _, _ = types.Type("", k.SubKeys[0])
+			}
+			if k.Pointer() {
+				ops = combineOp(k, jen.Id("v"), jen.Op("*").Add(o.Clone()), elemType)
+			} else {
+				ops = combineOp(k, r.Clone(), o.Clone(), elemType)
+			}
+		}
+		comment := k.Key + ": " + k.CombineType
+		if k.CombineType == "" || !combinable(k) {
+			comment = k.Key + ": first"
+		}
+		body = append(body, jen.Comment(comment))
+		switch {
+		case k.Pointer() && len(ops) > 0:
+			block := []jen.Code{jen.Id("v").Op(":=").Op("*").Add(r.Clone())}
+			block = append(block, ops...)
+			block = append(block, r.Clone().Op("=").Op("&").Id("v"))
+			body = append(body, jen.If(r.Clone().Op("==").Nil()).Block(
+				r.Clone().Op("=").Add(o.Clone()),
+			).Else().If(o.Clone().Op("!=").Nil()).Block(block...))
+		case k.Type == "<array>" && len(ops) > 0:
+			body = append(body, jen.If(r.Clone().Op("==").Nil()).Block(
+				r.Clone().Op("=").Add(o.Clone()),
+			).Else().If(o.Clone().Op("!=").Nil()).Block(ops...))
+		case len(ops) > 0:
+			body = append(body, ops...)
+		case k.Pointer() || k.Type == "<array>" || k.Type == "<data>" || k.Type == "<any>" || (k.Type == "<dictionary>" && len(k.SubKeys) < 1):
+			body = append(body, jen.If(r.Clone().Op("==").Nil()).Block(
+				r.Clone().Op("=").Add(o.Clone()),
+			))
+		default:
+			// required value, the first is kept
+			body = body[:len(body)-1]
+		}
+	}
+
+	block := []jen.Code{
+		jen.Id("r").Op(":=").Id("c"),
+		jen.For(jen.List(jen.Id("_"), jen.Id("o")).Op(":=").Range().Id("others")).Block(body...),
+		jen.Return(jen.Id("r")),
+	}
+	if len(body) < 1 {
+		// only required values, the first are kept
+		block = []jen.Code{jen.Return(jen.Id("c"))}
+	}
+
+	file.Comment("Combine returns the effective configuration of c combined with others as")
+	file.Comment("computed by a device with multiple declarations of the same type. Keys")
+	file.Comment("without a combine type (or one that can't apply) keep the first value set.")
+	file.Comment("Only top-level keys are combined: a dictionary is kept whole from the")
+	file.Comment("first declaration that sets it. Enum values not in the schema's list of")
+	file.Comment("values never replace the value combined so far.")
+	file.Func().Params(jen.Id("c").Id(name)).Id("Combine").Params(
+		jen.Id("others").Op("...").Id(name),
+	).Id(name).Block(block...)
+}
+
+// jenCombineEnumIndex generates the enum ordering helper used by the
+// enum-lowest and enum-highest combine types.
+func jenCombineEnumIndex(file *jen.File) {
+	file.Comment("combineEnumIndex returns the index of v in values, or -1 if v is not found.")
+	file.Func().Id("combineEnumIndex").Params(
+		jen.Id("v").String(),
+		jen.Id("values").Index().String(),
+	).Int().Block(
+		jen.For(jen.List(jen.Id("i"), jen.Id("value")).Op(":=").Range().Id("values")).Block(
+			jen.If(jen.Id("value").Op("==").Id("v")).Block(
+				jen.Return(jen.Id("i")),
+			),
+		),
+		jen.Return(jen.Lit(-1)),
+	)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"github.com/dave/jennifer/jen"
+)
+
+// SupportedOS is the applicability of a declaration type on a platform.
+type SupportedOS struct {
+	Introduced         string   `yaml:"introduced"`
+	Deprecated         string   `yaml:"deprecated,omitempty"`
+	Removed            string   `yaml:"removed,omitempty"`
+	AllowedEnrollments []string `yaml:"allowed-enrollments,omitempty"`
+	AllowedScopes      []string `yaml:"allowed-scopes,omitempty"`
+}
+
+// jenStrings generates a string slice literal of v.
+func jenStrings(v []string) jen.Code {
+	var elems []jen.Code
+	for _, s := range v {
+		elems = append(elems, jen.Lit(s))
+	}
+	return jen.Index().String().Values(elems...)
+}
+
+// jenMetadata generates the applicability metadata table of the
+// declaration types and a function to check it.
+func jenMetadata(file *jen.File, s *declSchema) {
+	file.Comment("DeclarationPlatform is the applicability of a declaration type on a platform.")
+	file.Type().Id("DeclarationPlatform").Struct(
+		jen.Comment("OS version the declaration type was introduced in (\"n/a\" if unsupported)"),
+		jen.Id("Introduced").String(),
+		jen.Comment("OS version the declaration type was deprecated in, if any (see DeclarationDeprecated)"),
+		jen.Id("Deprecated").String(),
+		jen.Comment("OS version the declaration type was removed in, if any"),
+		jen.Id("Removed").String(),
+		jen.Comment("enrollment types (e.g. \"device\", \"user\", \"supervised\") the declaration is allowed on; any if empty"),
+		jen.Id("AllowedEnrollments").Index().String(),
+		jen.Comment("channel scopes (\"system\" or \"user\") the declaration is allowed on; any if empty"),
+		jen.Id("AllowedScopes").Index().String(),
+	)
+
+	file.Comment("DeclarationMetadata is the applicability of a declaration type.")
+	file.Type().Id("DeclarationMetadata").Struct(
+		jen.Id("Title").String(),
+		jen.Comment("how multiple declarations of the type apply (e.g. \"single\", \"multiple\", or \"combined\")"),
+		jen.Id("Apply").String(),
+		jen.Comment("platform (e.g. \"iOS\" or \"macOS\") to applicability; any platform if empty"),
+		jen.Id("Platforms").Map(jen.String()).Id("DeclarationPlatform"),
+	)
+
+	types := jen.Dict{}
+	for _, d := range s.decls {
+		p := d.schema.Payload
+		platforms := jen.Dict{}
+		for name, sos := range p.SupportedOS {
+			fields := jen.Dict{jen.Id("Introduced"): jen.Lit(sos.Introduced)}
+			if sos.Deprecated != "" {
+				fields[jen.Id("Deprecated")] = jen.Lit(sos.Deprecated)
+			}
+			if sos.Removed != "" {
+				fields[jen.Id("Removed")] = jen.Lit(sos.Removed)
+			}
+			if len(sos.AllowedEnrollments) > 0 {
+				fields[jen.Id("AllowedEnrollments")] = jenStrings(sos.AllowedEnrollments)
+			}
+			if len(sos.AllowedScopes) > 0 {
+				fields[jen.Id("AllowedScopes")] = jenStrings(sos.AllowedScopes)
+			}
+			platforms[jen.Lit(name)] = jen.Values(fields)
+		}
+		fields := jen.Dict{jen.Id("Title"): jen.Lit(d.schema.Title)}
+		if p.Apply != "" {
+			fields[jen.Id("Apply")] = jen.Lit(p.Apply)
+		}
+		if len(p.SupportedOS) > 0 {
+			fields[jen.Id("Platforms")] = jen.Map(jen.String()).Id("DeclarationPlatform").Values(platforms)
+		}
+		types[jen.Lit(p.DeclarationType)] = jen.Values(fields)
+	}
+	file.Comment("Declarations is a map of declaration type to its applicability.")
+	file.Var().Id("Declarations").Op("=").Map(jen.String()).Id("DeclarationMetadata").Values(types)
+
+	contains := func(list jen.Code, v string) jen.Code {
+		return jen.Id("containsString").Call(list, jen.Id(v))
+	}
+	file.Comment("DeclarationAllowed reports whether a declaration of type declType is")
+	file.Comment("allowed on the scope (channel) and enrollment type of a device with the")
+	file.Comment("platform and OS version. Empty scope, enrollmentType, or osVersion")
+	file.Comment("arguments are not checked. Metadata missing from the schema does not")
+	file.Comment("restrict: a type without platforms is allowed on any platform, and one")
+	file.Comment("without allowed scopes or enrollment types on any of them. Unknown")
+	file.Comment("declaration types are not allowed.")
+	file.Func().Id("DeclarationAllowed").Params(
+		jen.List(jen.Id("declType"), jen.Id("scope"), jen.Id("enrollmentType"), jen.Id("platform"), jen.Id("osVersion")).String(),
+	).Bool().Block(
+		jen.List(jen.Id("m"), jen.Id("ok")).Op(":=").Id("Declarations").Index(jen.Id("declType")),
+		jen.If(jen.Op("!").Id("ok")).Block(
+			jen.Return(jen.False()),
+		).Else().If(jen.Len(jen.Id("m").Dot("Platforms")).Op("==").Lit(0)).Block(
+			jen.Return(jen.True()),
+		),
+		jen.List(jen.Id("p"), jen.Id("ok")).Op(":=").Id("m").Dot("Platforms").Index(jen.Id("platform")),
+		jen.If(jen.Op("!").Id("ok").Op("||").Id("p").Dot("Introduced").Op("==").Lit("n/a")).Block(
+			jen.Return(jen.False()),
+		),
+		jen.If(jen.Id("scope").Op("!=").Lit("").Op("&&").Len(jen.Id("p").Dot("AllowedScopes")).Op(">").Lit(0).Op("&&").Op("!").Add(contains(jen.Id("p").Dot("AllowedScopes"), "scope"))).Block(
+			jen.Return(jen.False()),
+		),
+		jen.If(jen.Id("enrollmentType").Op("!=").Lit("").Op("&&").Len(jen.Id("p").Dot("AllowedEnrollments")).Op(">").Lit(0).Op("&&").Op("!").Add(contains(jen.Id("p").Dot("AllowedEnrollments"), "enrollmentType"))).Block(
+			jen.Return(jen.False()),
+		),
+		jen.If(jen.Id("osVersion").Op("==").Lit("")).Block(
+			jen.Return(jen.True()),
+		),
+		jen.If(jen.Id("p").Dot("Introduced").Op("!=").Lit("").Op("&&").Id("compareOSVersion").Call(jen.Id("osVersion"), jen.Id("p").Dot("Introduced")).Op("<").Lit(0)).Block(
+			jen.Return(jen.False()),
+		),
+		jen.Return(jen.Id("p").Dot("Removed").Op("==").Lit("").Op("||").Id("p").Dot("Removed").Op("==").Lit("n/a").Op("||").Id("compareOSVersion").Call(jen.Id("osVersion"), jen.Id("p").Dot("Removed")).Op("<").Lit(0)),
+	)
+
+	file.Comment("DeclarationDeprecated reports whether declarations of type declType are")
+	file.Comment("deprecated on the platform at the OS version. An empty osVersion reports")
+	file.Comment("whether the type is deprecated in any version.")
+	file.Func().Id("DeclarationDeprecated").Params(
+		jen.List(jen.Id("declType"), jen.Id("platform"), jen.Id("osVersion")).String(),
+	).Bool().Block(
+		jen.Id("p").Op(":=").Id("Declarations").Index(jen.Id("declType")).Dot("Platforms").Index(jen.Id("platform")),
+		jen.If(jen.Id("p").Dot("Deprecated").Op("==").Lit("").Op("||").Id("p").Dot("Deprecated").Op("==").Lit("n/a")).Block(
+			jen.Return(jen.False()),
+		),
+		jen.Return(jen.Id("osVersion").Op("==").Lit("").Op("||").Id("compareOSVersion").Call(jen.Id("osVersion"), jen.Id("p").Dot("Deprecated")).Op(">=").Lit(0)),
+	)
+
+	file.Comment("containsString reports whether s is in list.")
+	file.Func().Id("containsString").Params(jen.Id("list").Index().String(), jen.Id("s").String()).Bool().Block(
+		jen.For(jen.List(jen.Id("_"), jen.Id("v")).Op(":=").Range().Id("list")).Block(
+			jen.If(jen.Id("v").Op("==").Id("s")).Block(
+				jen.Return(jen.True()),
+			),
+		),
+		jen.Return(jen.False()),
+	)
+
+	file.Comment("compareOSVersion compares the dotted numeric OS versions a and b,")
+	file.Comment("returning -1, 0, or 1. Missing components are treated as zero.")
+	file.Func().Id("compareOSVersion").Params(jen.List(jen.Id("a"), jen.Id("b")).String()).Int().Block(
+		jen.List(jen.Id("as"), jen.Id("bs")).Op(":=").List(
+			jen.Qual("strings", "Split").Call(jen.Id("a"), jen.Lit(".")),
+			jen.Qual("strings", "Split").Call(jen.Id("b"), jen.Lit(".")),
+		),
+		jen.For(jen.Id("i").Op(":=").Lit(0), jen.Id("i").Op("<").Len(jen.Id("as")).Op("||").Id("i").Op("<").Len(jen.Id("bs")), jen.Id("i").Op("++")).Block(
+			jen.Var().List(jen.Id("av"), jen.Id("bv")).Int(),
+			jen.If(jen.Id("i").Op("<").Len(jen.Id("as"))).Block(
+				jen.List(jen.Id("av"), jen.Id("_")).Op("=").Qual("strconv", "Atoi").Call(jen.Id("as").Index(jen.Id("i"))),
+			),
+			jen.If(jen.Id("i").Op("<").Len(jen.Id("bs"))).Block(
+				jen.List(jen.Id("bv"), jen.Id("_")).Op("=").Qual("strconv", "Atoi").Call(jen.Id("bs").Index(jen.Id("i"))),
+			),
+			jen.If(jen.Id("av").Op("<").Id("bv")).Block(
+				jen.Return(jen.Lit(-1)),
+			).Else().If(jen.Id("av").Op(">").Id("bv")).Block(
+				jen.Return(jen.Lit(1)),
+			),
+		),
+		jen.Return(jen.Lit(0)),
+	)
+}
@@ -0,0 +1,150 @@
+package ddm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestServerToken(t *testing.T) {
+	minLength := 6
+	p := &ConfigurationPasscodeSettings{MinimumLength: &minLength, BlockedApps: []string{"b", "a"}, GracePeriod: 5}
+	b, err := p.CanonicalJSON("pc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Identifier":"pc","Payload":{"BlockedApps":["b","a"],"GracePeriod":5,"MinimumLength":6},"Type":"com.apple.configuration.passcode.settings"}`
+	if string(b) != want {
+		t.Errorf("have %s, want %s", b, want)
+	}
+
+	token, err := p.ComputeServerToken("pc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an untyped payload with equal values gets the same token
+	var untyped map[string]interface{}
+	if err = json.Unmarshal([]byte(`{"MinimumLength": 6.0, "GracePeriod": 5e0, "BlockedApps": ["b", "a"]}`), &untyped); err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := declarationServerToken("com.apple.configuration.passcode.settings", "pc", untyped); other != token {
+		t.Errorf("untyped token %s, want %s", other, token)
+	}
+
+	for _, changed := range []func() (string, error){
+		func() (string, error) { return p.ComputeServerToken("other") },
+		func() (string, error) {
+			return (&ConfigurationPasscodeSettings{GracePeriod: 5}).ComputeServerToken("pc")
+		},
+		func() (string, error) { return (&ActivationSimple{}).ComputeServerToken("pc") },
+	} {
+		if other, _ := changed(); other == token {
+			t.Errorf("token %s unchanged", other)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config []string
+		want   string
+	}{
+		{"single", []string{`{"MinimumLength": 4, "GracePeriod": 5}`}, `{"MinimumLength": 4, "GracePeriod": 5}`},
+		{
+			"booleans",
+			[]string{`{"RequirePasscode": false, "ChangeAtNextAuth": true}`, `{"RequirePasscode": true, "ChangeAtNextAuth": false}`},
+			`{"RequirePasscode": true, "ChangeAtNextAuth": false}`,
+		},
+		{
+			"numbers",
+			[]string{`{"MinimumLength": 4, "MaximumFailedAttempts": 10, "GracePeriod": 5}`, `{"MinimumLength": 6, "MaximumFailedAttempts": 5, "GracePeriod": 1}`},
+			`{"MinimumLength": 6, "MaximumFailedAttempts": 5, "GracePeriod": 1}`,
+		},
+		{"unset optional takes other", []string{`{}`, `{"MinimumLength": 6}`}, `{"MinimumLength": 6}`},
+		{"enum highest", []string{`{"Complexity": "alphanumeric"}`, `{"Complexity": "complex"}`, `{"Complexity": "simple"}`}, `{"Complexity": "complex"}`},
+		{"unknown enum does not win", []string{`{"Complexity": "alphanumeric"}`, `{"Complexity": "unknown"}`}, `{"Complexity": "alphanumeric"}`},
+		{"unknown enum is kept", []string{`{"Complexity": "unknown"}`, `{"Complexity": "complex"}`}, `{"Complexity": "unknown"}`},
+		{
+			"sets",
+			[]string{`{"AllowedApps": ["a", "b", "c"], "BlockedApps": ["x"]}`, `{"AllowedApps": ["c", "a"], "BlockedApps": ["y", "x"]}`},
+			`{"AllowedApps": ["a", "c"], "BlockedApps": ["x", "y"]}`,
+		},
+		{"empty intersection", []string{`{"AllowedApps": ["a"]}`, `{"AllowedApps": ["b"]}`}, `{"AllowedApps": []}`},
+		{"dictionary kept whole", []string{`{"CustomRegex": {"Regex": "a"}}`, `{"CustomRegex": {"Regex": "b"}}`}, `{"CustomRegex": {"Regex": "a"}}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var configs []ConfigurationPasscodeSettings
+			for _, s := range tc.config {
+				var c ConfigurationPasscodeSettings
+				if err := json.Unmarshal([]byte(s), &c); err != nil {
+					t.Fatal(err)
+				}
+				configs = append(configs, c)
+			}
+			var want ConfigurationPasscodeSettings
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			have := configs[0].Combine(configs[1:]...)
+			if !reflect.DeepEqual(have, want) {
+				h, _ := json.Marshal(have)
+				w, _ := json.Marshal(want)
+				t.Errorf("have %s, want %s", h, w)
+			}
+		})
+	}
+}
+
+func TestDeclarationAllowed(t *testing.T) {
+	const (
+		passcode = "com.apple.configuration.passcode.settings"
+		mail     = "com.apple.configuration.account.mail"
+		identity = "com.apple.asset.useridentity"
+	)
+	for _, tc := range []struct {
+		declType, scope, enrollmentType, platform, osVersion string
+		want                                                 bool
+	}{
+		{passcode, "system", "device", "iOS", "17.0", true},
+		{passcode, "user", "device", "iOS", "17.0", false},
+		{passcode, "", "local", "iOS", "", false},
+		{passcode, "", "", "iOS", "14.8", false},
+		{passcode, "", "", "iOS", "15", true},
+		{passcode, "", "", "tvOS", "", false},
+		// removed: n/a
+		{passcode, "", "", "macOS", "14.0", true},
+		// no supportedOS
+		{identity, "user", "local", "visionOS", "1.0", true},
+		// no allowed scopes or enrollments
+		{mail, "user", "local", "macOS", "15.0", true},
+		{mail, "", "", "macOS", "13.9", false},
+		{mail, "", "", "macOS", "16.0", false},
+		{mail, "", "", "tvOS", "", false},
+		{"com.apple.unknown", "", "", "iOS", "", false},
+	} {
+		have := DeclarationAllowed(tc.declType, tc.scope, tc.enrollmentType, tc.platform, tc.osVersion)
+		if have != tc.want {
+			t.Errorf("%+v: have %v", tc, have)
+		}
+	}
+}
+
+func TestDeclarationDeprecated(t *testing.T) {
+	const mail = "com.apple.configuration.account.mail"
+	for _, tc := range []struct {
+		declType, platform, osVersion string
+		want                          bool
+	}{
+		{mail, "iOS", "17.5", false},
+		{mail, "iOS", "18.0", true},
+		{mail, "iOS", "", true},
+		{mail, "macOS", "", false},
+		{"com.apple.configuration.passcode.settings", "iOS", "", false},
+		{"com.apple.unknown", "iOS", "", false},
+	} {
+		if have := DeclarationDeprecated(tc.declType, tc.platform, tc.osVersion); have != tc.want {
+			t.Errorf("%+v: have %v", tc, have)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/dave/jennifer/jen"
+)
+
+// jenServerToken generates canonical serialization and ServerToken
+// computation for the declaration payload type of d.
+func jenServerToken(file *jen.File, d declaration) {
+	declType := d.schema.Payload.DeclarationType
+
+	file.Comment("CanonicalJSON returns the canonical serialization of the " + declType)
+	file.Comment("declaration identifier with payload p used to compute its ServerToken.")
+	file.Func().Params(jen.Id("p").Op("*").Id(d.name)).Id("CanonicalJSON").Params(jen.Id("identifier").String()).Params(jen.Index().Byte(), jen.Error()).Block(
+		jen.Return(jen.Id("declarationCanonicalJSON").Call(jen.Lit(declType), jen.Id("identifier"), jen.Id("p"))),
+	)
+
+	file.Comment("ComputeServerToken returns a ServerToken for the " + declType)
+	file.Comment("declaration identifier with payload p. It is the same token as for an")
+	file.Comment("equal untyped declaration.")
+	file.Func().Params(jen.Id("p").Op("*").Id(d.name)).Id("ComputeServerToken").Params(jen.Id("identifier").String()).Params(jen.String(), jen.Error()).Block(
+		jen.Return(jen.Id("declarationServerToken").Call(jen.Lit(declType), jen.Id("identifier"), jen.Id("p"))),
+	)
+}
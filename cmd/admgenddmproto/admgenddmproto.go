@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
+)
+
+// ProtocolSchema is a declarative device management protocol document
+// (e.g. the tokens or declaration-items response).
+type ProtocolSchema struct {
+	Title       string             `yaml:"title"`
+	Description string             `yaml:"description"`
+	PayloadKeys []admgen.SchemaKey `yaml:"payloadkeys"`
+}
+
+// message is a protocol document to generate.
+type message struct {
+	name   string
+	schema *ProtocolSchema
+}
+
+// protoSchema is the result of walking the protocol schema files.
+type protoSchema struct {
+	messages []message
+
+	files int
+	hash  string
+}
+
+// messageName returns the Go type name of the protocol document s read
+// from path. The title is preferred and the filename is used otherwise.
+func messageName(path string, s *ProtocolSchema) string {
+	if name := admgen.FieldName(s.Title); name != "" {
+		return name
+	}
+	return admgen.FieldName(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+}
+
+// walk reads the protocol schema files in dir.
+func walk(src *admgen.Source, dir string, overlay *admgen.Overlay) (*protoSchema, error) {
+	s := &protoSchema{}
+	var hashes []string
+	names := make(map[string]string)
+	err := src.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error accessing path %s: %w", path, err)
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+
+		data, err := src.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		hashes = append(hashes, filepath.ToSlash(rel)+" "+admgen.ContentHash(data))
+
+		ps := &ProtocolSchema{}
+		if err = overlay.Decode(bytes.NewReader(data), path, ps); err != nil {
+			return fmt.Errorf("decoding yaml in %s: %w", path, err)
+		}
+
+		if len(ps.PayloadKeys) < 1 {
+			return nil
+		}
+		name := messageName(path, ps)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("duplicate message name %s in %s and %s", name, other, path)
+		}
+		names[name] = path
+		s.messages = append(s.messages, message{name: name, schema: ps})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(s.messages, func(i, j int) bool { return s.messages[i].name < s.messages[j].name })
+	s.files = len(hashes)
+	s.hash = admgen.ContentHash([]byte(strings.Join(hashes, "\n")))
+	return s, nil
+}
+
+func jenGo(pkgName string, s *protoSchema, header []string, rev admgen.Revision, w io.Writer) error {
+	file := jen.NewFile(pkgName)
+	for _, line := range header {
+		file.PackageComment(line)
+	}
+	types := admgen.NewJSONTypes(file)
+	for _, m := range s.messages {
+		comment := m.name + " is the " + m.schema.Title + " declarative device management protocol document."
+		if m.schema.Title == "" {
+			comment = m.name + " is a declarative device management protocol document."
+		}
+		if err := types.Struct(m.name, comment, m.schema.PayloadKeys); err != nil {
+			return err
+		}
+		types.Constructor(m.name)
+	}
+	file.Comment("ProtocolSchemaVersion is the Apple Device Management schema revision these types were generated from.")
+	file.Const().Id("ProtocolSchemaVersion").Op("=").Lit(rev.Version())
+	return file.Render(w)
+}
+
+func main() {
+	g := admgen.NewGenerator("admgenddmproto")
+	g.Parse()
+	g.Open(nil)
+
+	schema, err := walk(g.Source, g.Dir, g.Overlay)
+	if err != nil {
+		admgen.Fatalf(1, "walking directory: %v", err)
+	}
+	header := g.Header(g.Summary(schema.files, schema.hash))
+
+	output := new(bytes.Buffer)
+	if err = jenGo(*g.Pkg, schema, header, g.Revision, output); err != nil {
+		admgen.Fatalf(2, "rendering output: %v", err)
+	}
+	g.Finish(output.Bytes())
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jessepeterson/admgen"
+	"github.com/jessepeterson/admgen/internal/gentest"
+)
+
+func generate(t *testing.T) []byte {
+	t.Helper()
+	s, err := walk(nil, "../../testdata/schema/declarative/protocol", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	header := []string{"Code generated by \"admgenddmproto\"; DO NOT EDIT."}
+	if err = jenGo("ddm", s, header, admgen.Revision{Tag: "test"}, &b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestGenerated(t *testing.T) {
+	generated := generate(t)
+	gentest.Golden(t, "testdata/proto.go.golden", generated)
+	gentest.Run(t, map[string][]byte{
+		"proto.go":      generated,
+		"proto_test.go": gentest.ReadFile(t, "testdata/proto_test.go"),
+	})
+}
@@ -0,0 +1,79 @@
+package ddm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewRequired(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{
+			name: "DeclarationItemsResponse",
+			v:    NewDeclarationItemsResponse(),
+			want: `{"Declarations":{"Activations":[],"Configurations":[]},"DeclarationsToken":""}`,
+		},
+		{
+			name: "StatusReport",
+			v:    NewStatusReport(),
+			want: `{"StatusItems":{}}`,
+		},
+		{
+			name: "TokensResponse",
+			v:    NewTokensResponse(),
+			want: `{"SyncTokens":{"DeclarationsToken":"","Timestamp":"0001-01-01T00:00:00Z"}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(tc.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := string(b); have != tc.want {
+				t.Errorf("have %s, want %s", have, tc.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	fullReport := true
+	tokens := NewTokensResponse()
+	tokens.SyncTokens.DeclarationsToken = "token"
+	tokens.SyncTokens.Timestamp = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := NewDeclarationItemsResponse()
+	items.DeclarationsToken = "token"
+	items.Declarations.Activations = append(items.Declarations.Activations, DeclarationItemsResponseDeclarationsActivationsItem{Identifier: "act", ServerToken: "1"})
+	report := NewStatusReport()
+	report.StatusItems["device"] = map[string]interface{}{"model": "x"}
+	report.Errors = []StatusReportErrorsItem{{StatusItem: "device"}}
+	report.FullReport = &fullReport
+
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+		new  interface{}
+	}{
+		{"TokensResponse", tokens, &TokensResponse{}},
+		{"DeclarationItemsResponse", items, &DeclarationItemsResponse{}},
+		{"StatusReport", report, &StatusReport{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(tc.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(b, tc.new); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tc.new, tc.v) {
+				t.Errorf("have %+v, want %+v", tc.new, tc.v)
+			}
+		})
+	}
+}
@@ -1,139 +1,390 @@
 package main
 
 import (
+	"bytes"
+	_ "embed"
 	"flag"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"gopkg.in/yaml.v3"
-
 	"github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
 )
 
-type Key struct {
-	Key     string `yaml:"key"`
-	Type    string `yaml:"type"`
-	SubKeys []Key  `yaml:"subkeys,omitempty"`
-}
-
 type DeclarationPayloadSchema struct {
 	DeclarationType string `yaml:"declarationtype"`
 }
 
 type DeclarationSchema struct {
 	Payload     DeclarationPayloadSchema `yaml:"payload"`
-	PayloadKeys []Key                    `yaml:"payloadkeys"`
+	PayloadKeys []admgen.SchemaKey       `yaml:"payloadkeys"`
 }
 
-func walkForAssetRefs(list *[][]string, cur []string, keys []Key) {
+// arrayElem is the path element representing every item of an array.
+const arrayElem = "*"
+
+// ref is a payload key path containing the identifier of a referenced declaration.
+type ref struct {
+	Path []string `json:"path" yaml:"path,flow"`
+	// allowed declaration types of the referenced declaration
+	Types []string `json:"types" yaml:"types"`
+}
+
+// refSchema is the result of walking the declaration schema files.
+type refSchema struct {
+	// declaration type to the references in its payload
+	Refs map[string][]ref
+	// all declaration types found
+	Types []string
+
+	files int
+	hash  string
+}
+
+// walkForRefs finds keys with reference metadata (assettypes) in the
+// schema. Keys that are arrays with reference metadata are arrays of
+// references.
+func walkForRefs(list *[]ref, cur []string, keys []admgen.SchemaKey) {
 	for _, key := range keys {
-		if strings.HasSuffix(key.Key, "AssetReference") {
-			*list = append(*list, append(cur, key.Key))
-		} else if key.Type == "<dictionary>" {
-			walkForAssetRefs(list, append(cur, key.Key), key.SubKeys)
+		// copy cur so that sibling paths do not share storage
+		walkKeyForRefs(list, append(cur[:len(cur):len(cur)], key.Key), key)
+	}
+}
+
+func walkKeyForRefs(list *[]ref, path []string, key admgen.SchemaKey) {
+	if len(key.AssetTypes) > 0 {
+		if key.Type == "<array>" {
+			path = append(path, arrayElem)
+		}
+		*list = append(*list, ref{Path: path, Types: key.AssetTypes})
+		return
+	}
+	switch key.Type {
+	case "<dictionary>":
+		walkForRefs(list, path, key.SubKeys)
+	case "<array>":
+		itemPath := append(path[:len(path):len(path)], arrayElem)
+		for _, item := range key.SubKeys {
+			walkKeyForRefs(list, itemPath, item)
 		}
 	}
 }
 
-func jenGo(pkgName, name string, refs map[string][][]string, w io.Writer) {
-	file := jen.NewFile(pkgName)
-	file.PackageComment("Code generated by \"admgenddmrefs\"; DO NOT EDIT.")
-	d := jen.Dict{}
-	for k, v := range refs {
-		paths := []jen.Code{}
-		for _, v2 := range v {
-			elems := []jen.Code{}
-			for _, elem := range v2 {
-				elems = append(elems, jen.Lit(elem))
+// expandTypes expands any declaration type patterns in the reference
+// types to the matching declaration types of the schema. Patterns that
+// match no declaration types are dropped and returned as warnings.
+func (s *refSchema) expandTypes() (warnings []string) {
+	var declTypes []string
+	for declType := range s.Refs {
+		declTypes = append(declTypes, declType)
+	}
+	sort.Strings(declTypes)
+	for _, declType := range declTypes {
+		refs := s.Refs[declType]
+		for i, r := range refs {
+			var types []string
+			seen := make(map[string]bool)
+			for _, pattern := range r.Types {
+				matched := false
+				for _, t := range s.Types {
+					if ok, _ := path.Match(pattern, t); ok {
+						matched = true
+						if !seen[t] {
+							seen[t] = true
+							types = append(types, t)
+						}
+					}
+				}
+				if !matched && strings.ContainsAny(pattern, "*?[") {
+					warnings = append(warnings, fmt.Sprintf("%s: key %s: type pattern %s matches no declaration types", declType, strings.Join(r.Path, "."), pattern))
+				} else if !matched && !seen[pattern] {
+					seen[pattern] = true
+					types = append(types, pattern)
+				}
 			}
-			paths = append(paths, jen.Line().Values(elems...))
+			sort.Strings(types)
+			refs[i].Types = types
+		}
+	}
+	return
+}
+
+func jenStringSlices(v [][]string) jen.Code {
+	paths := []jen.Code{}
+	for _, v2 := range v {
+		elems := []jen.Code{}
+		for _, elem := range v2 {
+			elems = append(elems, jen.Lit(elem))
 		}
-		paths = append(paths, jen.Line())
-		d[jen.Lit(k)] = jen.Values(paths...)
+		paths = append(paths, jen.Line().Values(elems...))
+	}
+	paths = append(paths, jen.Line())
+	return jen.Values(paths...)
+}
+
+// jenFuncs generates functions to extract and rewrite the references
+// of a declaration payload using the name key paths. The exported
+// functions are named with prefix.
+func jenFuncs(file *jen.File, name, prefix string) {
+	walkName := name + "Walk"
+	visitName := name + "Visit"
+
+	file.Comment(prefix + "ExtractRefs returns the identifiers of the declarations referenced by")
+	file.Comment("payload. declType is the declaration type of payload. Payload is not modified.")
+	file.Func().Id(prefix+"ExtractRefs").Params(
+		jen.Id("declType").String(),
+		jen.Id("payload").Map(jen.String()).Interface(),
+	).Index().String().Block(
+		jen.Var().Id("ids").Index().String(),
+		jen.For(jen.List(jen.Id("_"), jen.Id("path")).Op(":=").Range().Id(name).Index(jen.Id("declType"))).Block(
+			jen.Id(visitName).Call(jen.Id("payload"), jen.Id("path"), jen.Func().Params(jen.Id("id").String()).Block(
+				jen.Id("ids").Op("=").Append(jen.Id("ids"), jen.Id("id")),
+			)),
+		),
+		jen.Return(jen.Id("ids")),
+	)
+
+	file.Comment(visitName + " calls fn for each non-empty identifier at path in v.")
+	file.Func().Id(visitName).Params(
+		jen.Id("v").Interface(),
+		jen.Id("path").Index().String(),
+		jen.Id("fn").Func().Params(jen.String()),
+	).Block(
+		jen.If(jen.Len(jen.Id("path")).Op("<").Lit(1)).Block(
+			jen.If(jen.List(jen.Id("id"), jen.Id("ok")).Op(":=").Id("v").Assert(jen.String()), jen.Id("ok").Op("&&").Id("id").Op("!=").Lit("")).Block(
+				jen.Id("fn").Call(jen.Id("id")),
+			),
+			jen.Return(),
+		),
+		jen.Switch(jen.Id("t").Op(":=").Id("v").Assert(jen.Type())).Block(
+			jen.Case(jen.Map(jen.String()).Interface()).Block(
+				jen.If(jen.List(jen.Id("mv"), jen.Id("ok")).Op(":=").Id("t").Index(jen.Id("path").Index(jen.Lit(0))), jen.Id("ok")).Block(
+					jen.Id(visitName).Call(jen.Id("mv"), jen.Id("path").Index(jen.Lit(1), jen.Empty()), jen.Id("fn")),
+				),
+			),
+			jen.Case(jen.Index().Interface()).Block(
+				jen.If(jen.Id("path").Index(jen.Lit(0)).Op("==").Lit(arrayElem)).Block(
+					jen.For(jen.List(jen.Id("_"), jen.Id("item")).Op(":=").Range().Id("t")).Block(
+						jen.Id(visitName).Call(jen.Id("item"), jen.Id("path").Index(jen.Lit(1), jen.Empty()), jen.Id("fn")),
+					),
+				),
+			),
+			jen.Case(jen.Index().String()).Block(
+				jen.If(jen.Id("path").Index(jen.Lit(0)).Op("==").Lit(arrayElem).Op("&&").Len(jen.Id("path")).Op("==").Lit(1)).Block(
+					jen.For(jen.List(jen.Id("_"), jen.Id("id")).Op(":=").Range().Id("t")).Block(
+						jen.If(jen.Id("id").Op("!=").Lit("")).Block(
+							jen.Id("fn").Call(jen.Id("id")),
+						),
+					),
+				),
+			),
+		),
+	)
+
+	file.Comment(prefix + "RewriteRefs replaces the identifiers of the declarations referenced by")
+	file.Comment("payload with the result of calling fn. declType is the declaration type")
+	file.Comment("of payload. Payload is modified in place.")
+	file.Func().Id(prefix+"RewriteRefs").Params(
+		jen.Id("declType").String(),
+		jen.Id("payload").Map(jen.String()).Interface(),
+		jen.Id("fn").Func().Params(jen.Id("old").String()).String(),
+	).Block(
+		jen.For(jen.List(jen.Id("_"), jen.Id("path")).Op(":=").Range().Id(name).Index(jen.Id("declType"))).Block(
+			jen.Id(walkName).Call(jen.Id("payload"), jen.Id("path"), jen.Id("fn")),
+		),
+	)
+
+	file.Comment(walkName + " calls fn for each non-empty identifier at path in v and")
+	file.Comment("replaces the identifier with the result.")
+	file.Func().Id(walkName).Params(
+		jen.Id("v").Interface(),
+		jen.Id("path").Index().String(),
+		jen.Id("fn").Func().Params(jen.String()).String(),
+	).Interface().Block(
+		jen.If(jen.Len(jen.Id("path")).Op("<").Lit(1)).Block(
+			jen.If(jen.List(jen.Id("id"), jen.Id("ok")).Op(":=").Id("v").Assert(jen.String()), jen.Id("ok").Op("&&").Id("id").Op("!=").Lit("")).Block(
+				jen.Return(jen.Id("fn").Call(jen.Id("id"))),
+			),
+			jen.Return(jen.Id("v")),
+		),
+		jen.Switch(jen.Id("t").Op(":=").Id("v").Assert(jen.Type())).Block(
+			jen.Case(jen.Map(jen.String()).Interface()).Block(
+				jen.If(jen.List(jen.Id("mv"), jen.Id("ok")).Op(":=").Id("t").Index(jen.Id("path").Index(jen.Lit(0))), jen.Id("ok")).Block(
+					jen.Id("t").Index(jen.Id("path").Index(jen.Lit(0))).Op("=").Id(walkName).Call(jen.Id("mv"), jen.Id("path").Index(jen.Lit(1), jen.Empty()), jen.Id("fn")),
+				),
+			),
+			jen.Case(jen.Index().Interface()).Block(
+				jen.If(jen.Id("path").Index(jen.Lit(0)).Op("==").Lit(arrayElem)).Block(
+					jen.For(jen.Id("i").Op(":=").Range().Id("t")).Block(
+						jen.Id("t").Index(jen.Id("i")).Op("=").Id(walkName).Call(jen.Id("t").Index(jen.Id("i")), jen.Id("path").Index(jen.Lit(1), jen.Empty()), jen.Id("fn")),
+					),
+				),
+			),
+			jen.Case(jen.Index().String()).Block(
+				jen.If(jen.Id("path").Index(jen.Lit(0)).Op("==").Lit(arrayElem).Op("&&").Len(jen.Id("path")).Op("==").Lit(1)).Block(
+					jen.For(jen.List(jen.Id("i"), jen.Id("id")).Op(":=").Range().Id("t")).Block(
+						jen.If(jen.Id("id").Op("!=").Lit("")).Block(
+							jen.Id("t").Index(jen.Id("i")).Op("=").Id("fn").Call(jen.Id("id")),
+						),
+					),
+				),
+			),
+		),
+		jen.Return(jen.Id("v")),
+	)
+}
+
+func jenGo(pkgName, name, prefix string, s *refSchema, header []string, rev admgen.Revision, noFuncs bool, w io.Writer) error {
+	file := jen.NewFile(pkgName)
+	for _, line := range header {
+		file.PackageComment(line)
+	}
+	paths := jen.Dict{}
+	types := jen.Dict{}
+	for k, refs := range s.Refs {
+		var p, t [][]string
+		for _, r := range refs {
+			p = append(p, r.Path)
+			t = append(t, r.Types)
+		}
+		paths[jen.Lit(k)] = jenStringSlices(p)
+		types[jen.Lit(k)] = jenStringSlices(t)
 	}
 	file.Comment(name + " is a map of declaration type to payload key paths.")
 	file.Comment("These key paths contain the identifiers of dependent declarations.")
-	file.Var().Id(name).Op("=").Map(jen.String()).Index().Index().String().Values(d)
-	file.Render(w)
+	file.Comment("A path element of \"" + arrayElem + "\" refers to every item of an array.")
+	file.Var().Id(name).Op("=").Map(jen.String()).Index().Index().String().Values(paths)
+	file.Comment(name + "Types is a map of declaration type to the allowed declaration types")
+	file.Comment("of the dependent declarations at the same index of the key paths in " + name + ".")
+	file.Var().Id(name + "Types").Op("=").Map(jen.String()).Index().Index().String().Values(types)
+	file.Comment(name + "SchemaVersion is the Apple Device Management schema revision " + name + " was generated from.")
+	file.Const().Id(name + "SchemaVersion").Op("=").Lit(rev.Version())
+	if !noFuncs {
+		jenFuncs(file, name, prefix)
+		jenValidate(file, name, prefix)
+		jenServerToken(file, name, prefix)
+	}
+	return file.Render(w)
 }
 
-func walk(dir string) (map[string][][]string, error) {
-	refs := make(map[string][][]string)
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+// walk finds references in the schema files in dir.
+func walk(src *admgen.Source, dir string, overlay *admgen.Overlay) (*refSchema, error) {
+	s := &refSchema{Refs: make(map[string][]ref)}
+	var hashes []string
+	err := src.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return fmt.Errorf("error accessing path %s: %w", path, err)
 		}
 
-		if info.IsDir() || filepath.Ext(path) != ".yaml" {
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
 			return nil
 		}
 
-		f, err := os.Open(path)
+		data, err := src.ReadFile(path)
 		if err != nil {
-			return fmt.Errorf("opening %s: %w", path, err)
+			return fmt.Errorf("reading %s: %w", path, err)
 		}
-		defer f.Close()
+		rel, _ := filepath.Rel(dir, path)
+		hashes = append(hashes, filepath.ToSlash(rel)+" "+admgen.ContentHash(data))
 
-		d := &DeclarationSchema{}
-		if err = yaml.NewDecoder(f).Decode(d); err != nil {
+		ds := &DeclarationSchema{}
+		if err = overlay.Decode(bytes.NewReader(data), path, ds); err != nil {
 			return fmt.Errorf("decoding yaml in %s: %w", path, err)
 		}
 
-		if !strings.HasPrefix(d.Payload.DeclarationType, "com.apple.configuration.") {
+		if ds.Payload.DeclarationType == "" {
 			return nil
 		}
+		s.Types = append(s.Types, ds.Payload.DeclarationType)
 
-		tlist := [][]string{}
-		walkForAssetRefs(&tlist, nil, d.PayloadKeys)
+		var list []ref
+		walkForRefs(&list, nil, ds.PayloadKeys)
 
-		if len(tlist) > 0 {
-			refs[d.Payload.DeclarationType] = tlist
+		if len(list) > 0 {
+			s.Refs[ds.Payload.DeclarationType] = list
 		}
 
 		return nil
 	})
-
-	return refs, err
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(s.Types)
+	for _, w := range s.expandTypes() {
+		admgen.Warnf("%s", w)
+	}
+	s.files = len(hashes)
+	s.hash = admgen.ContentHash([]byte(strings.Join(hashes, "\n")))
+	return s, nil
 }
 
+// errata are built-in schema patches. Currently these add reference
+// metadata to keys that are missing it in Apple's schema.
+//
+//go:embed errata.yaml
+var errata string
+
 func main() {
+	g := admgen.NewGenerator("admgenddmrefs")
 	var (
-		flPkg  = flag.String("pkg", "main", "Name of generated package")
-		flName = flag.String("name", "idRefs", "Name of variable")
-		flOut  = flag.String("o", "-", "output filename; \"-\" for stdout")
+		flName     = flag.String("name", "idRefs", "Name of variable")
+		flPrefix   = flag.String("prefix", "", "prefix of the names of the exported helper functions and types")
+		flNoErrata = flag.Bool("no-errata", false, "do not apply built-in schema errata patches")
+		flFormat   = flag.String("format", "go", "output format: go, json, yaml, or the declaration type reference graph as dot or mermaid")
+		flNoFuncs  = flag.Bool("no-funcs", false, "do not generate reference helper functions, validator, or ServerToken helpers")
 	)
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <yaml-dir>\n", os.Args[0])
-		flag.PrintDefaults()
+	g.Parse()
+
+	switch *flFormat {
+	case "go", "json", "yaml", "dot", "mermaid":
+	default:
+		admgen.Fatalf(2, "unknown format: %s", *flFormat)
 	}
-	flag.Parse()
 
-	var output io.Writer = os.Stdout
-	var err error
-	if *flOut != "-" {
-		output, err = os.OpenFile(*flOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	var overlay *admgen.Overlay
+	if !*flNoErrata {
+		var err error
+		overlay, err = admgen.ReadOverlay(strings.NewReader(errata))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error opening output file: %v\n", err)
-			os.Exit(2)
+			admgen.Fatalf(2, "loading errata: %v", err)
 		}
 	}
+	g.Open(overlay)
 
-	if len(flag.Args()) != 1 {
-		fmt.Fprintln(os.Stderr, "ERROR: must specify exactly one path to yaml files")
-		os.Exit(2)
+	schema, err := walk(g.Source, g.Dir, g.Overlay)
+	if err != nil {
+		admgen.Fatalf(1, "walking directory: %v", err)
 	}
+	source := g.Summary(schema.files, schema.hash)
+	header := g.Header(source)
 
-	refs, err := walk(flag.Args()[0])
+	output := new(bytes.Buffer)
+	switch *flFormat {
+	case "go":
+		err = jenGo(*g.Pkg, *flName, *flPrefix, schema, header, g.Revision, *flNoFuncs, output)
+	case "dot":
+		err = writeDOT(output, schema, header)
+	case "mermaid":
+		err = writeMermaid(output, schema, header)
+	case "json", "yaml":
+		data := refData{
+			SchemaVersion: g.Revision.Version(),
+			Source:        source,
+			Patches:       g.Overlay.Applied(),
+		}
+		if *flFormat == "json" {
+			err = writeJSON(output, schema, data)
+		} else {
+			err = writeYAML(output, schema, data, header)
+		}
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: walking directory: %v\n", err)
-		os.Exit(1)
+		admgen.Fatalf(2, "rendering output: %v", err)
 	}
-
-	// explicitly add the one non-configuration asset reference
-	refs["com.apple.activation.simple"] = [][]string{{"StandardConfigurations"}}
-
-	jenGo(*flPkg, *flName, refs, output)
+	g.Finish(output.Bytes())
 }
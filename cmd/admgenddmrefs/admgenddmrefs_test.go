@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/admgen"
+	"github.com/jessepeterson/admgen/internal/gentest"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandTypes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		types    []string
+		want     []string
+		warnings int
+	}{
+		{
+			name:  "exact",
+			types: []string{"com.apple.asset.useridentity"},
+			want:  []string{"com.apple.asset.useridentity"},
+		},
+		{
+			name:  "exact unknown",
+			types: []string{"com.apple.asset.other"},
+			want:  []string{"com.apple.asset.other"},
+		},
+		{
+			name:  "pattern",
+			types: []string{"com.apple.configuration.*"},
+			want:  []string{"com.apple.configuration.a", "com.apple.configuration.b"},
+		},
+		{
+			name:  "pattern and exact overlap",
+			types: []string{"com.apple.configuration.b", "com.apple.configuration.*"},
+			want:  []string{"com.apple.configuration.a", "com.apple.configuration.b"},
+		},
+		{
+			name:     "unmatched pattern",
+			types:    []string{"com.apple.management.*", "com.apple.asset.useridentity"},
+			want:     []string{"com.apple.asset.useridentity"},
+			warnings: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &refSchema{
+				Refs: map[string][]ref{
+					"com.apple.activation.simple": {{Path: []string{"StandardConfigurations", arrayElem}, Types: tc.types}},
+				},
+				Types: []string{
+					"com.apple.activation.simple",
+					"com.apple.asset.useridentity",
+					"com.apple.configuration.a",
+					"com.apple.configuration.b",
+				},
+			}
+			warnings := s.expandTypes()
+			if have := s.Refs["com.apple.activation.simple"][0].Types; !reflect.DeepEqual(have, tc.want) {
+				t.Errorf("have %v, want %v", have, tc.want)
+			}
+			if len(warnings) != tc.warnings {
+				t.Errorf("have %d warnings (%v), want %d", len(warnings), warnings, tc.warnings)
+			}
+		})
+	}
+}
+
+func TestWalkForRefs(t *testing.T) {
+	keys := []admgen.SchemaKey{
+		{Key: "Credential", Type: "<string>", AssetTypes: []string{"com.apple.asset.useridentity"}},
+		{Key: "Configurations", Type: "<array>", AssetTypes: []string{"com.apple.configuration.*"}},
+		{Key: "Accounts", Type: "<array>", SubKeys: []admgen.SchemaKey{
+			{Key: "Item", Type: "<dictionary>", SubKeys: []admgen.SchemaKey{
+				{Key: "Identity", Type: "<string>", AssetTypes: []string{"com.apple.asset.useridentity"}},
+				{Key: "Name", Type: "<string>"},
+			}},
+		}},
+		{Key: "Settings", Type: "<dictionary>", SubKeys: []admgen.SchemaKey{
+			{Key: "Cert", Type: "<string>", AssetTypes: []string{"com.apple.asset.credential.certificate"}},
+		}},
+	}
+	var list []ref
+	walkForRefs(&list, nil, keys)
+	want := [][]string{
+		{"Credential"},
+		{"Configurations", arrayElem},
+		{"Accounts", arrayElem, "Identity"},
+		{"Settings", "Cert"},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("have %d refs, want %d", len(list), len(want))
+	}
+	for i, r := range list {
+		if !reflect.DeepEqual(r.Path, want[i]) {
+			t.Errorf("ref %d: have %v, want %v", i, r.Path, want[i])
+		}
+	}
+}
+
+// testHeader is the header of the outputs generated in tests.
+var testHeader = []string{"Code generated by \"admgenddmrefs\"; DO NOT EDIT."}
+
+// testSchema walks the test schema with the errata applied.
+func testSchema(t *testing.T) *refSchema {
+	t.Helper()
+	overlay, err := admgen.ReadOverlay(strings.NewReader(errata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := walk(nil, "../../testdata/schema/declarative/declarations", overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// generate generates Go code from the test schema with the variable name
+// and the helper prefix.
+func generate(t *testing.T, name, prefix string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := jenGo("ddm", name, prefix, testSchema(t), testHeader, admgen.Revision{Tag: "test"}, false, &b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestGenerated(t *testing.T) {
+	generated := generate(t, "idRefs", "")
+	gentest.Golden(t, "testdata/refs.go.golden", generated)
+	gentest.Run(t, map[string][]byte{
+		"refs.go":      generated,
+		"refs_test.go": gentest.ReadFile(t, "testdata/refs_test.go"),
+	})
+}
+
+func TestGeneratedPrefix(t *testing.T) {
+	// two sets of helpers in one package
+	gentest.Run(t, map[string][]byte{
+		"refs.go":  generate(t, "idRefs", ""),
+		"other.go": generate(t, "otherRefs", "Other"),
+	})
+}
+
+func TestGraph(t *testing.T) {
+	s := testSchema(t)
+	for _, tc := range []struct {
+		golden string
+		write  func(io.Writer, *refSchema, []string) error
+	}{
+		{"testdata/refs.dot.golden", writeDOT},
+		{"testdata/refs.mmd.golden", writeMermaid},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tc.write(&b, s, testHeader); err != nil {
+				t.Fatal(err)
+			}
+			gentest.Golden(t, tc.golden, b.Bytes())
+		})
+	}
+}
+
+func TestData(t *testing.T) {
+	s := testSchema(t)
+	data := refData{SchemaVersion: "test", Source: "declarations", Patches: []string{"errata"}}
+	for _, tc := range []struct {
+		golden    string
+		write     func(io.Writer) error
+		unmarshal func([]byte, interface{}) error
+	}{
+		{"testdata/refs.json.golden", func(w io.Writer) error { return writeJSON(w, s, data) }, json.Unmarshal},
+		{"testdata/refs.yaml.golden", func(w io.Writer) error { return writeYAML(w, s, data, testHeader) }, yaml.Unmarshal},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tc.write(&b); err != nil {
+				t.Fatal(err)
+			}
+			gentest.Golden(t, tc.golden, b.Bytes())
+			var have refData
+			if err := tc.unmarshal(b.Bytes(), &have); err != nil {
+				t.Fatal(err)
+			}
+			want := data
+			want.Refs = s.Refs
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("have %+v, want %+v", have, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// refData is the data (JSON and YAML) output of the reference paths.
+type refData struct {
+	SchemaVersion string           `json:"schemaVersion" yaml:"schemaVersion"`
+	Source        string           `json:"source" yaml:"source"`
+	Patches       []string         `json:"patches,omitempty" yaml:"patches,omitempty"`
+	Refs          map[string][]ref `json:"refs" yaml:"refs"`
+}
+
+// writeJSON writes the reference paths of s as JSON.
+// Map keys are sorted by the encoder for deterministic output.
+func writeJSON(w io.Writer, s *refSchema, data refData) error {
+	data.Refs = s.Refs
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// writeYAML writes the reference paths of s as YAML.
+// Map keys are sorted by the encoder for deterministic output.
+func writeYAML(w io.Writer, s *refSchema, data refData, header []string) error {
+	for _, line := range header {
+		if _, err := fmt.Fprintf(w, "# %s\n", line); err != nil {
+			return err
+		}
+	}
+	data.Refs = s.Refs
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// edge is a reference from one declaration type to another.
+type edge struct {
+	from, to string
+	// payload key paths of the reference
+	paths []string
+}
+
+// edges returns the declaration type reference graph edges of s sorted
+// by declaration types. Also returned are the declaration types that
+// are part of the graph grouped by kind (e.g. "configuration").
+func (s *refSchema) edges() ([]edge, map[string][]string) {
+	byPair := make(map[[2]string]*edge)
+	nodes := make(map[string]bool)
+	for from, refs := range s.Refs {
+		for _, r := range refs {
+			for _, to := range r.Types {
+				e, ok := byPair[[2]string{from, to}]
+				if !ok {
+					e = &edge{from: from, to: to}
+					byPair[[2]string{from, to}] = e
+				}
+				e.paths = append(e.paths, strings.Join(r.Path, "."))
+				nodes[from] = true
+				nodes[to] = true
+			}
+		}
+	}
+	var edges []edge
+	for _, e := range byPair {
+		sort.Strings(e.paths)
+		edges = append(edges, *e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		return edges[i].to < edges[j].to
+	})
+	kinds := make(map[string][]string)
+	for t := range nodes {
+		kinds[declarationKind(t)] = append(kinds[declarationKind(t)], t)
+	}
+	for _, types := range kinds {
+		sort.Strings(types)
+	}
+	return edges, kinds
+}
+
+// declarationKind returns the kind of declaration type t.
+// For example "com.apple.configuration.passcode.settings" is a "configuration".
+func declarationKind(t string) string {
+	elems := strings.Split(t, ".")
+	if len(elems) < 3 {
+		return "other"
+	}
+	return elems[2]
+}
+
+func sortedKeys(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeDOT writes the declaration type reference graph of s as Graphviz DOT.
+func writeDOT(w io.Writer, s *refSchema, header []string) error {
+	var b strings.Builder
+	for _, line := range header {
+		fmt.Fprintf(&b, "// %s\n", line)
+	}
+	b.WriteString("digraph declarations {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	edges, kinds := s.edges()
+	for _, kind := range sortedKeys(kinds) {
+		fmt.Fprintf(&b, "\tsubgraph %q {\n\t\tlabel=%q;\n", "cluster_"+kind, kind)
+		for _, t := range kinds[kind] {
+			fmt.Fprintf(&b, "\t\t%q;\n", t)
+		}
+		b.WriteString("\t}\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "\t%q -> %q [label=%q];\n", e.from, e.to, strings.Join(e.paths, "\n"))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMermaid writes the declaration type reference graph of s as a
+// Mermaid flowchart.
+func writeMermaid(w io.Writer, s *refSchema, header []string) error {
+	var b strings.Builder
+	for _, line := range header {
+		fmt.Fprintf(&b, "%%%% %s\n", line)
+	}
+	b.WriteString("flowchart LR\n")
+	edges, kinds := s.edges()
+	// Mermaid node IDs can't contain all characters of declaration types
+	ids := make(map[string]string)
+	for _, kind := range sortedKeys(kinds) {
+		fmt.Fprintf(&b, "\tsubgraph %s\n", kind)
+		for _, t := range kinds[kind] {
+			ids[t] = fmt.Sprintf("n%d", len(ids))
+			fmt.Fprintf(&b, "\t\t%s[\"%s\"]\n", ids[t], t)
+		}
+		b.WriteString("\tend\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "\t%s -->|\"%s\"| %s\n", ids[e.from], strings.Join(e.paths, "<br>"), ids[e.to])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,127 @@
+package ddm
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func decode(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+const mailPayload = `{
+	"Accounts": [{"HostName": "a", "CredentialsAssetReference": "cred1"}, {"HostName": "b"}],
+	"SMIMEIdentities": ["id1", "", "id2"]
+}`
+
+func TestExtractRefs(t *testing.T) {
+	payload := decode(t, mailPayload)
+	orig := decode(t, mailPayload)
+	ids := ExtractRefs("com.apple.configuration.account.mail", payload)
+	sort.Strings(ids)
+	if want := []string{"cred1", "id1", "id2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("have %v, want %v", ids, want)
+	}
+	if !reflect.DeepEqual(payload, orig) {
+		t.Error("payload modified")
+	}
+	if ids := ExtractRefs("com.apple.unknown", payload); len(ids) != 0 {
+		t.Errorf("unknown type: have %v", ids)
+	}
+}
+
+func TestExtractRefsConcurrent(t *testing.T) {
+	payload := decode(t, mailPayload)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ExtractRefs("com.apple.configuration.account.mail", payload)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRewriteRefs(t *testing.T) {
+	payload := decode(t, mailPayload)
+	RewriteRefs("com.apple.configuration.account.mail", payload, func(old string) string { return "new-" + old })
+	ids := ExtractRefs("com.apple.configuration.account.mail", payload)
+	sort.Strings(ids)
+	if want := []string{"new-cred1", "new-id1", "new-id2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("have %v, want %v", ids, want)
+	}
+}
+
+func kinds(issues []DeclarationIssue) map[DeclarationIssueKind][]string {
+	m := make(map[DeclarationIssueKind][]string)
+	for _, i := range issues {
+		m[i.Kind] = append(m[i.Kind], i.Identifier)
+	}
+	return m
+}
+
+func TestValidateDeclarations(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		decls []Declaration
+		want  map[DeclarationIssueKind][]string
+	}{
+		{
+			name: "valid",
+			decls: []Declaration{
+				{Type: "com.apple.activation.simple", Identifier: "act", Payload: map[string]interface{}{"StandardConfigurations": []interface{}{"mail"}}},
+				{Type: "com.apple.configuration.account.mail", Identifier: "mail", Payload: map[string]interface{}{"SMIMEIdentities": []interface{}{}}},
+			},
+			want: map[DeclarationIssueKind][]string{},
+		},
+		{
+			name: "duplicate",
+			decls: []Declaration{
+				{Type: "com.apple.activation.simple", Identifier: "act"},
+				{Type: "com.apple.activation.simple", Identifier: "act"},
+			},
+			want: map[DeclarationIssueKind][]string{IssueDuplicate: {"act"}},
+		},
+		{
+			name: "dangling",
+			decls: []Declaration{
+				{Type: "com.apple.activation.simple", Identifier: "act", Payload: map[string]interface{}{"StandardConfigurations": []interface{}{"missing"}}},
+			},
+			want: map[DeclarationIssueKind][]string{IssueDanglingRef: {"act"}},
+		},
+		{
+			name: "wrong type and unreferenced",
+			decls: []Declaration{
+				{Type: "com.apple.activation.simple", Identifier: "act", Payload: map[string]interface{}{"StandardConfigurations": []interface{}{"asset"}}},
+				{Type: "com.apple.asset.useridentity", Identifier: "asset"},
+				{Type: "com.apple.configuration.passcode.settings", Identifier: "pc"},
+			},
+			want: map[DeclarationIssueKind][]string{IssueWrongType: {"act"}, IssueUnreferenced: {"pc"}},
+		},
+		{
+			name: "cycle",
+			decls: []Declaration{
+				{Type: "com.apple.activation.simple", Identifier: "act", Payload: map[string]interface{}{"StandardConfigurations": []interface{}{"mail"}}},
+				{Type: "com.apple.configuration.account.mail", Identifier: "mail", Payload: map[string]interface{}{
+					"Accounts": []interface{}{map[string]interface{}{"CredentialsAssetReference": "act"}},
+				}},
+			},
+			want: map[DeclarationIssueKind][]string{IssueWrongType: {"mail"}, IssueCycle: {"act"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if have := kinds(ValidateDeclarations(tc.decls)); !reflect.DeepEqual(have, tc.want) {
+				t.Errorf("have %v, want %v", have, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
+)
+
+// jenServerToken generates canonical serialization and ServerToken
+// computation for the generated Declaration type named with prefix.
+func jenServerToken(file *jen.File, name, prefix string) {
+	file.Comment("CanonicalJSON returns the canonical serialization of d used to compute")
+	file.Comment("its ServerToken. Object keys are sorted, insignificant whitespace is")
+	file.Comment("removed, and numbers are normalized. The ServerToken itself is excluded.")
+	file.Func().Params(jen.Id("d").Op("*").Id(prefix+"Declaration")).Id("CanonicalJSON").Params().Params(jen.Index().Byte(), jen.Error()).Block(
+		jen.Return(jen.Id(name+"CanonicalJSON").Call(jen.Id("d").Dot("Type"), jen.Id("d").Dot("Identifier"), jen.Id("d").Dot("Payload"))),
+	)
+
+	file.Comment("ComputeServerToken returns a ServerToken for d that changes whenever the")
+	file.Comment("type, identifier, or payload of d changes. Identical declarations always")
+	file.Comment("produce identical tokens.")
+	file.Func().Params(jen.Id("d").Op("*").Id(prefix+"Declaration")).Id("ComputeServerToken").Params().Params(jen.String(), jen.Error()).Block(
+		jen.Return(jen.Id(name+"ServerToken").Call(jen.Id("d").Dot("Type"), jen.Id("d").Dot("Identifier"), jen.Id("d").Dot("Payload"))),
+	)
+
+	admgen.JenServerToken(file, name)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"github.com/dave/jennifer/jen"
+)
+
+// issueKinds are the generated kinds of declaration issues.
+var issueKinds = []struct {
+	name    string
+	value   string
+	comment string
+}{
+	{"IssueDuplicate", "duplicate identifier", "is a declaration identifier used more than once."},
+	{"IssueDanglingRef", "dangling reference", "is a reference to a declaration not in the set."},
+	{"IssueWrongType", "wrong reference type", "is a reference to a declaration of a disallowed type."},
+	{"IssueUnreferenced", "unreferenced", "is an asset or configuration not referenced by any declaration."},
+	{"IssueCycle", "reference cycle", "is a declaration that references itself through other declarations."},
+}
+
+// jenValidate generates a validator for the references between a set of
+// declarations using the name key paths and types. The exported types,
+// constants, and function are named with prefix.
+func jenValidate(file *jen.File, name, prefix string) {
+	file.Comment(prefix + "Declaration is a declarative device management declaration.")
+	file.Type().Id(prefix+"Declaration").Struct(
+		jen.Id("Type").String(),
+		jen.Id("Identifier").String(),
+		jen.Id("ServerToken").String().Comment("see ComputeServerToken"),
+		jen.Id("Payload").Map(jen.String()).Interface(),
+	)
+
+	file.Comment(prefix + "DeclarationIssueKind is the kind of problem found with a declaration.")
+	file.Type().Id(prefix + "DeclarationIssueKind").String()
+
+	var consts []jen.Code
+	for _, k := range issueKinds {
+		consts = append(consts,
+			jen.Comment(prefix+k.name+" "+k.comment),
+			jen.Id(prefix+k.name).Id(prefix+"DeclarationIssueKind").Op("=").Lit(k.value),
+		)
+	}
+	file.Const().Defs(consts...)
+
+	file.Comment(prefix + "DeclarationIssue is a problem found validating a set of declarations.")
+	file.Type().Id(prefix+"DeclarationIssue").Struct(
+		jen.Id("Kind").Id(prefix+"DeclarationIssueKind"),
+		jen.Comment("identifier of the declaration with the issue"),
+		jen.Id("Identifier").String(),
+		jen.Comment("referenced identifiers related to the issue, if any"),
+		jen.Id("Refs").Index().String(),
+	)
+
+	file.Comment("Error adapts a standard Go error for " + prefix + "DeclarationIssue.")
+	file.Func().Params(jen.Id("i").Id(prefix+"DeclarationIssue")).Id("Error").Params().String().Block(
+		jen.If(jen.Len(jen.Id("i").Dot("Refs")).Op("<").Lit(1)).Block(
+			jen.Return(jen.Qual("fmt", "Sprintf").Call(jen.Lit("%s: %s"), jen.Id("i").Dot("Identifier"), jen.Id("i").Dot("Kind"))),
+		),
+		jen.Return(jen.Qual("fmt", "Sprintf").Call(
+			jen.Lit("%s: %s: %s"),
+			jen.Id("i").Dot("Identifier"),
+			jen.Id("i").Dot("Kind"),
+			jen.Qual("strings", "Join").Call(jen.Id("i").Dot("Refs"), jen.Lit(", ")),
+		)),
+	)
+
+	issue := func(kind string, id jen.Code, refs ...jen.Code) jen.Code {
+		d := jen.Dict{
+			jen.Id("Kind"):       jen.Id(prefix + kind),
+			jen.Id("Identifier"): id,
+		}
+		if len(refs) > 0 {
+			d[jen.Id("Refs")] = refs[0]
+		}
+		return jen.Id("issues").Op("=").Append(jen.Id("issues"), jen.Id(prefix+"DeclarationIssue").Values(d))
+	}
+
+	file.Comment(prefix + "ValidateDeclarations checks the references between a set of declarations.")
+	file.Comment("It reports duplicate identifiers, references to declarations missing from")
+	file.Comment("decls, references to declarations of a disallowed type, assets and")
+	file.Comment("configurations that are not referenced, and reference cycles.")
+	file.Func().Id(prefix+"ValidateDeclarations").Params(
+		jen.Id("decls").Index().Id(prefix+"Declaration"),
+	).Index().Id(prefix+"DeclarationIssue").Block(
+		jen.Var().Id("issues").Index().Id(prefix+"DeclarationIssue"),
+		jen.Id("byID").Op(":=").Make(jen.Map(jen.String()).Op("*").Id(prefix+"Declaration")),
+		jen.For(jen.Id("i").Op(":=").Range().Id("decls")).Block(
+			jen.If(jen.List(jen.Id("_"), jen.Id("ok")).Op(":=").Id("byID").Index(jen.Id("decls").Index(jen.Id("i")).Dot("Identifier")), jen.Id("ok")).Block(
+				issue("IssueDuplicate", jen.Id("decls").Index(jen.Id("i")).Dot("Identifier")),
+				jen.Continue(),
+			),
+			jen.Id("byID").Index(jen.Id("decls").Index(jen.Id("i")).Dot("Identifier")).Op("=").Op("&").Id("decls").Index(jen.Id("i")),
+		),
+		jen.Line(),
+		jen.Comment("follow the references of each declaration"),
+		jen.Id("edges").Op(":=").Make(jen.Map(jen.String()).Index().String()),
+		jen.Id("referenced").Op(":=").Make(jen.Map(jen.String()).Bool()),
+		jen.For(jen.Id("i").Op(":=").Range().Id("decls")).Block(
+			jen.Id("d").Op(":=").Op("&").Id("decls").Index(jen.Id("i")),
+			jen.If(jen.Id("byID").Index(jen.Id("d").Dot("Identifier")).Op("!=").Id("d")).Block(
+				jen.Continue(),
+			),
+			jen.For(jen.List(jen.Id("j"), jen.Id("path")).Op(":=").Range().Id(name).Index(jen.Id("d").Dot("Type"))).Block(
+				jen.Var().Id("allowed").Index().String(),
+				jen.If(jen.Id("types").Op(":=").Id(name+"Types").Index(jen.Id("d").Dot("Type")), jen.Id("j").Op("<").Len(jen.Id("types"))).Block(
+					jen.Id("allowed").Op("=").Id("types").Index(jen.Id("j")),
+				),
+				jen.Id(name+"Visit").Call(jen.Id("d").Dot("Payload"), jen.Id("path"), jen.Func().Params(jen.Id("id").String()).Block(
+					jen.List(jen.Id("ref"), jen.Id("ok")).Op(":=").Id("byID").Index(jen.Id("id")),
+					jen.If(jen.Op("!").Id("ok")).Block(
+						issue("IssueDanglingRef", jen.Id("d").Dot("Identifier"), jen.Index().String().Values(jen.Id("id"))),
+						jen.Return(),
+					),
+					jen.Id("referenced").Index(jen.Id("id")).Op("=").True(),
+					jen.Id("edges").Index(jen.Id("d").Dot("Identifier")).Op("=").Append(jen.Id("edges").Index(jen.Id("d").Dot("Identifier")), jen.Id("id")),
+					jen.Id("found").Op(":=").Len(jen.Id("allowed")).Op("<").Lit(1),
+					jen.For(jen.List(jen.Id("_"), jen.Id("t")).Op(":=").Range().Id("allowed")).Block(
+						jen.Id("found").Op("=").Id("found").Op("||").Id("t").Op("==").Id("ref").Dot("Type"),
+					),
+					jen.If(jen.Op("!").Id("found")).Block(
+						issue("IssueWrongType", jen.Id("d").Dot("Identifier"), jen.Index().String().Values(jen.Id("id"))),
+					),
+				)),
+			),
+		),
+		jen.Line(),
+		jen.Comment("assets and configurations are only useful if referenced"),
+		jen.For(jen.Id("i").Op(":=").Range().Id("decls")).Block(
+			jen.Id("d").Op(":=").Op("&").Id("decls").Index(jen.Id("i")),
+			jen.If(jen.Id("byID").Index(jen.Id("d").Dot("Identifier")).Op("!=").Id("d").Op("||").Id("referenced").Index(jen.Id("d").Dot("Identifier"))).Block(
+				jen.Continue(),
+			),
+			jen.If(
+				jen.Qual("strings", "HasPrefix").Call(jen.Id("d").Dot("Type"), jen.Lit("com.apple.asset.")).Op("||").
+					Qual("strings", "HasPrefix").Call(jen.Id("d").Dot("Type"), jen.Lit("com.apple.configuration.")),
+			).Block(
+				issue("IssueUnreferenced", jen.Id("d").Dot("Identifier")),
+			),
+		),
+		jen.Line(),
+		jen.Comment("depth-first search for cycles"),
+		jen.Const().Defs(
+			jen.Id("unvisited").Op("=").Iota(),
+			jen.Id("visiting"),
+			jen.Id("visited"),
+		),
+		jen.Id("state").Op(":=").Make(jen.Map(jen.String()).Int()),
+		jen.Var().Id("stack").Index().String(),
+		jen.Var().Id("visit").Func().Params(jen.Id("id").String()),
+		jen.Id("visit").Op("=").Func().Params(jen.Id("id").String()).Block(
+			jen.Id("state").Index(jen.Id("id")).Op("=").Id("visiting"),
+			jen.Id("stack").Op("=").Append(jen.Id("stack"), jen.Id("id")),
+			jen.For(jen.List(jen.Id("_"), jen.Id("ref")).Op(":=").Range().Id("edges").Index(jen.Id("id"))).Block(
+				jen.Switch(jen.Id("state").Index(jen.Id("ref"))).Block(
+					jen.Case(jen.Id("unvisited")).Block(
+						jen.Id("visit").Call(jen.Id("ref")),
+					),
+					jen.Case(jen.Id("visiting")).Block(
+						jen.For(jen.Id("i").Op(":=").Len(jen.Id("stack")).Op("-").Lit(1), jen.Id("i").Op(">=").Lit(0), jen.Id("i").Op("--")).Block(
+							jen.If(jen.Id("stack").Index(jen.Id("i")).Op("==").Id("ref")).Block(
+								jen.Id("cycle").Op(":=").Append(jen.Append(jen.Index().String().Call(jen.Nil()), jen.Id("stack").Index(jen.Id("i"), jen.Empty()).Op("...")), jen.Id("ref")),
+								issue("IssueCycle", jen.Id("ref"), jen.Id("cycle")),
+								jen.Break(),
+							),
+						),
+					),
+				),
+			),
+			jen.Id("stack").Op("=").Id("stack").Index(jen.Empty(), jen.Len(jen.Id("stack")).Op("-").Lit(1)),
+			jen.Id("state").Index(jen.Id("id")).Op("=").Id("visited"),
+		),
+		jen.For(jen.Id("i").Op(":=").Range().Id("decls")).Block(
+			jen.If(jen.Id("state").Index(jen.Id("decls").Index(jen.Id("i")).Dot("Identifier")).Op("==").Id("unvisited")).Block(
+				jen.Id("visit").Call(jen.Id("decls").Index(jen.Id("i")).Dot("Identifier")),
+			),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("issues")),
+	)
+}
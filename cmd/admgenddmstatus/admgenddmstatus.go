@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/jessepeterson/admgen"
+)
+
+type StatusPayloadSchema struct {
+	StatusItemType string `yaml:"statusitemtype"`
+}
+
+// StatusSchema is a declarative device management status item.
+type StatusSchema struct {
+	Title       string              `yaml:"title"`
+	Payload     StatusPayloadSchema `yaml:"payload"`
+	PayloadKeys []admgen.SchemaKey  `yaml:"payloadkeys"`
+}
+
+// item is a status item to generate.
+type item struct {
+	name  string
+	title string
+	// value of the status item
+	value admgen.SchemaKey
+}
+
+// statusSchema is the result of walking the status schema files.
+type statusSchema struct {
+	items []item
+
+	files int
+	hash  string
+}
+
+// itemValue returns the key describing the value of status item name.
+// The payload keys either describe the item itself (a single key named
+// for the last element of the item name) or the keys of an object.
+func itemValue(name string, keys []admgen.SchemaKey) admgen.SchemaKey {
+	elems := strings.Split(name, ".")
+	if len(keys) == 1 && keys[0].Key == elems[len(elems)-1] {
+		return keys[0]
+	}
+	return admgen.SchemaKey{Type: "<dictionary>", SubKeys: keys}
+}
+
+// insert adds the status item value at elems to the tree of keys.
+func insert(keys *[]admgen.SchemaKey, elems []string, value admgen.SchemaKey) error {
+	for i := range *keys {
+		k := &(*keys)[i]
+		if k.Key != elems[0] {
+			continue
+		}
+		if len(elems) == 1 || k.Type != "<dictionary>" {
+			return errors.New("conflicts with another status item")
+		}
+		return insert(&k.SubKeys, elems[1:], value)
+	}
+	if len(elems) == 1 {
+		value.Key = elems[0]
+		// status reports may be partial so nothing is required
+		value.Presence = "optional"
+		*keys = append(*keys, value)
+		return nil
+	}
+	*keys = append(*keys, admgen.SchemaKey{Key: elems[0], Type: "<dictionary>", Presence: "optional"})
+	return insert(&(*keys)[len(*keys)-1].SubKeys, elems[1:], value)
+}
+
+// walk reads the status schema files in dir.
+func walk(src *admgen.Source, dir string, overlay *admgen.Overlay) (*statusSchema, error) {
+	s := &statusSchema{}
+	var hashes []string
+	err := src.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error accessing path %s: %w", path, err)
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+
+		data, err := src.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		hashes = append(hashes, filepath.ToSlash(rel)+" "+admgen.ContentHash(data))
+
+		ss := &StatusSchema{}
+		if err = overlay.Decode(bytes.NewReader(data), path, ss); err != nil {
+			return fmt.Errorf("decoding yaml in %s: %w", path, err)
+		}
+
+		if ss.Payload.StatusItemType == "" {
+			return nil
+		}
+		s.items = append(s.items, item{
+			name:  ss.Payload.StatusItemType,
+			title: ss.Title,
+			value: itemValue(ss.Payload.StatusItemType, ss.PayloadKeys),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(s.items, func(i, j int) bool { return s.items[i].name < s.items[j].name })
+	s.files = len(hashes)
+	s.hash = admgen.ContentHash([]byte(strings.Join(hashes, "\n")))
+	return s, nil
+}
+
+// constName returns the name of the constant for status item name.
+func constName(name string) string {
+	return "StatusItem" + admgen.FieldName(name)
+}
+
+func jenGo(pkgName, name string, s *statusSchema, header []string, rev admgen.Revision, w io.Writer) error {
+	file := jen.NewFile(pkgName)
+	for _, line := range header {
+		file.PackageComment(line)
+	}
+
+	var consts []jen.Code
+	var names []jen.Code
+	var keys []admgen.SchemaKey
+	for _, i := range s.items {
+		if i.title != "" {
+			consts = append(consts, jen.Comment(constName(i.name)+" is the "+i.title+" status item."))
+		}
+		consts = append(consts, jen.Id(constName(i.name)).Op("=").Lit(i.name))
+		names = append(names, jen.Line().Id(constName(i.name)))
+		if err := insert(&keys, strings.Split(i.name, "."), i.value); err != nil {
+			return fmt.Errorf("status item %s: %w", i.name, err)
+		}
+	}
+	if len(consts) > 0 {
+		file.Const().Defs(consts...)
+	}
+	names = append(names, jen.Line())
+	file.Comment("StatusItemNames are the names of all status items.")
+	file.Var().Id("StatusItemNames").Op("=").Index().String().Values(names...)
+
+	types := admgen.NewJSONTypes(file)
+	if err := types.Struct(name, name+" is the tree of status items of a (possibly partial) status report.", keys); err != nil {
+		return err
+	}
+
+	jenMerge(file, name)
+
+	file.Comment(name + "SchemaVersion is the Apple Device Management schema revision " + name + " was generated from.")
+	file.Const().Id(name + "SchemaVersion").Op("=").Lit(rev.Version())
+	return file.Render(w)
+}
+
+// jenMerge generates functions to look up status items and merge
+// partial status reports into a full state.
+func jenMerge(file *jen.File, name string) {
+	file.Comment("LookupStatusItem returns the value of the status item name (one of the")
+	file.Comment("StatusItem constants) in the decoded JSON status items.")
+	file.Func().Id("LookupStatusItem").Params(
+		jen.Id("items").Map(jen.String()).Interface(),
+		jen.Id("name").String(),
+	).Params(jen.Interface(), jen.Bool()).Block(
+		jen.Var().Id("v").Interface().Op("=").Id("items"),
+		jen.For(jen.List(jen.Id("_"), jen.Id("elem")).Op(":=").Range().Qual("strings", "Split").Call(jen.Id("name"), jen.Lit("."))).Block(
+			jen.List(jen.Id("m"), jen.Id("ok")).Op(":=").Id("v").Assert(jen.Map(jen.String()).Interface()),
+			jen.If(jen.Op("!").Id("ok")).Block(
+				jen.Return(jen.Nil(), jen.False()),
+			),
+			jen.If(jen.List(jen.Id("v"), jen.Id("ok")).Op("=").Id("m").Index(jen.Id("elem")), jen.Op("!").Id("ok")).Block(
+				jen.Return(jen.Nil(), jen.False()),
+			),
+		),
+		jen.Return(jen.Id("v"), jen.True()),
+	)
+
+	file.Comment("MergeStatusItems merges the decoded JSON status items of a partial status")
+	file.Comment("report in src into the full state dst. Objects are merged key by key.")
+	file.Comment("Arrays of objects with an \"identifier\" are merged by identifier: items")
+	file.Comment("replace the item with the same identifier in dst or are added, and items")
+	file.Comment("with \"_removed\" set are removed. All other values (including other")
+	file.Comment("arrays) replace the value in dst. Merge a full status report into an empty")
+	file.Comment("state to replace the state.")
+	file.Func().Id("MergeStatusItems").Params(
+		jen.List(jen.Id("dst"), jen.Id("src")).Map(jen.String()).Interface(),
+	).Block(
+		jen.For(jen.List(jen.Id("k"), jen.Id("sv")).Op(":=").Range().Id("src")).Block(
+			jen.Switch(jen.Id("sv").Op(":=").Id("sv").Assert(jen.Type())).Block(
+				jen.Case(jen.Map(jen.String()).Interface()).Block(
+					jen.List(jen.Id("dm"), jen.Id("ok")).Op(":=").Id("dst").Index(jen.Id("k")).Assert(jen.Map(jen.String()).Interface()),
+					jen.If(jen.Op("!").Id("ok")).Block(
+						jen.Id("dm").Op("=").Make(jen.Map(jen.String()).Interface()),
+						jen.Id("dst").Index(jen.Id("k")).Op("=").Id("dm"),
+					),
+					jen.Id("MergeStatusItems").Call(jen.Id("dm"), jen.Id("sv")),
+				),
+				jen.Case(jen.Index().Interface()).Block(
+					jen.List(jen.Id("da"), jen.Id("_")).Op(":=").Id("dst").Index(jen.Id("k")).Assert(jen.Index().Interface()),
+					jen.Id("dst").Index(jen.Id("k")).Op("=").Id("mergeStatusArray").Call(jen.Id("da"), jen.Id("sv")),
+				),
+				jen.Default().Block(
+					jen.Id("dst").Index(jen.Id("k")).Op("=").Id("sv"),
+				),
+			),
+		),
+	)
+
+	file.Comment("statusItemID returns the identifier of the status array item v.")
+	file.Func().Id("statusItemID").Params(jen.Id("v").Interface()).Params(jen.String(), jen.Bool()).Block(
+		jen.List(jen.Id("m"), jen.Id("_")).Op(":=").Id("v").Assert(jen.Map(jen.String()).Interface()),
+		jen.List(jen.Id("id"), jen.Id("ok")).Op(":=").Id("m").Index(jen.Lit("identifier")).Assert(jen.String()),
+		jen.Return(jen.Id("id"), jen.Id("ok")),
+	)
+
+	file.Comment("mergeStatusArray merges the items of the status array src into dst by")
+	file.Comment("identifier. src replaces dst unless all of its items have an identifier.")
+	file.Func().Id("mergeStatusArray").Params(
+		jen.List(jen.Id("dst"), jen.Id("src")).Index().Interface(),
+	).Index().Interface().Block(
+		jen.For(jen.List(jen.Id("_"), jen.Id("v")).Op(":=").Range().Id("src")).Block(
+			jen.If(jen.List(jen.Id("_"), jen.Id("ok")).Op(":=").Id("statusItemID").Call(jen.Id("v")), jen.Op("!").Id("ok")).Block(
+				jen.Return(jen.Id("src")),
+			),
+		),
+		jen.Id("merged").Op(":=").Append(jen.Make(jen.Index().Interface(), jen.Lit(0), jen.Len(jen.Id("dst")).Op("+").Len(jen.Id("src"))), jen.Id("dst").Op("...")),
+		jen.For(jen.List(jen.Id("_"), jen.Id("v")).Op(":=").Range().Id("src")).Block(
+			jen.List(jen.Id("id"), jen.Id("_")).Op(":=").Id("statusItemID").Call(jen.Id("v")),
+			jen.Id("i").Op(":=").Lit(0),
+			jen.For(jen.Id("i").Op("<").Len(jen.Id("merged"))).Block(
+				jen.If(jen.List(jen.Id("mid"), jen.Id("ok")).Op(":=").Id("statusItemID").Call(jen.Id("merged").Index(jen.Id("i"))), jen.Id("ok").Op("&&").Id("mid").Op("==").Id("id")).Block(
+					jen.Break(),
+				),
+				jen.Id("i").Op("++"),
+			),
+			jen.List(jen.Id("removed"), jen.Id("_")).Op(":=").Id("v").Assert(jen.Map(jen.String()).Interface()).Index(jen.Lit("_removed")).Assert(jen.Bool()),
+			jen.Switch().Block(
+				jen.Case(jen.Id("i").Op("<").Len(jen.Id("merged")).Op("&&").Id("removed")).Block(
+					jen.Id("merged").Op("=").Append(jen.Id("merged").Index(jen.Empty(), jen.Id("i")), jen.Id("merged").Index(jen.Id("i").Op("+").Lit(1), jen.Empty()).Op("...")),
+				),
+				jen.Case(jen.Id("i").Op("<").Len(jen.Id("merged"))).Block(
+					jen.Id("merged").Index(jen.Id("i")).Op("=").Id("v"),
+				),
+				jen.Case(jen.Op("!").Id("removed")).Block(
+					jen.Id("merged").Op("=").Append(jen.Id("merged"), jen.Id("v")),
+				),
+			),
+		),
+		jen.Return(jen.Id("merged")),
+	)
+
+	file.Comment("MergeStatusJSON merges the JSON status items of a partial status report")
+	file.Comment("(the StatusItems object of the report) in data into the full state dst.")
+	file.Comment("Status items unknown to " + name + " are kept.")
+	file.Func().Id("MergeStatusJSON").Params(
+		jen.Id("dst").Map(jen.String()).Interface(),
+		jen.Id("data").Index().Byte(),
+	).Error().Block(
+		jen.Var().Id("src").Map(jen.String()).Interface(),
+		jen.If(jen.Id("err").Op(":=").Qual("encoding/json", "Unmarshal").Call(jen.Id("data"), jen.Op("&").Id("src")), jen.Id("err").Op("!=").Nil()).Block(
+			jen.Return(jen.Id("err")),
+		),
+		jen.Id("MergeStatusItems").Call(jen.Id("dst"), jen.Id("src")),
+		jen.Return(jen.Nil()),
+	)
+
+	file.Comment("Decode" + name + " decodes the status items in items (for example the")
+	file.Comment("full state merged by MergeStatusItems) into a " + name + " for reading.")
+	file.Comment("Unknown status items are ignored, so keep items to persist the state.")
+	file.Func().Id("Decode"+name).Params(
+		jen.Id("items").Map(jen.String()).Interface(),
+	).Params(jen.Op("*").Id(name), jen.Error()).Block(
+		jen.List(jen.Id("b"), jen.Id("err")).Op(":=").Qual("encoding/json", "Marshal").Call(jen.Id("items")),
+		jen.If(jen.Id("err").Op("!=").Nil()).Block(
+			jen.Return(jen.Nil(), jen.Id("err")),
+		),
+		jen.Id("r").Op(":=").New(jen.Id(name)),
+		jen.Return(jen.Id("r"), jen.Qual("encoding/json", "Unmarshal").Call(jen.Id("b"), jen.Id("r"))),
+	)
+}
+
+func main() {
+	g := admgen.NewGenerator("admgenddmstatus")
+	flName := flag.String("name", "StatusItems", "Name of status item tree type")
+	g.Parse()
+	g.Open(nil)
+
+	schema, err := walk(g.Source, g.Dir, g.Overlay)
+	if err != nil {
+		admgen.Fatalf(1, "walking directory: %v", err)
+	}
+	header := g.Header(g.Summary(schema.files, schema.hash))
+
+	output := new(bytes.Buffer)
+	if err = jenGo(*g.Pkg, *flName, schema, header, g.Revision, output); err != nil {
+		admgen.Fatalf(2, "rendering output: %v", err)
+	}
+	g.Finish(output.Bytes())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/admgen"
+	"github.com/jessepeterson/admgen/internal/gentest"
+)
+
+func TestInsert(t *testing.T) {
+	str := admgen.SchemaKey{Type: "<string>"}
+	dict := admgen.SchemaKey{Type: "<dictionary>", SubKeys: []admgen.SchemaKey{{Key: "a", Type: "<string>"}}}
+	for _, tc := range []struct {
+		name  string
+		items []string
+		value admgen.SchemaKey
+		err   bool
+	}{
+		{"siblings", []string{"device.a", "device.b"}, str, false},
+		{"nested in dictionary", []string{"device.a", "device.b.c"}, dict, false},
+		{"duplicate", []string{"device.a", "device.a"}, str, true},
+		{"below a value", []string{"device.a", "device.a.b"}, str, true},
+		{"into a dictionary value", []string{"device.a", "device.a.b"}, dict, false},
+		{"key of a dictionary value", []string{"device.a", "device.a.a"}, dict, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var keys []admgen.SchemaKey
+			var err error
+			for _, name := range tc.items {
+				if err = insert(&keys, strings.Split(name, "."), tc.value); err != nil {
+					break
+				}
+			}
+			if tc.err != (err != nil) {
+				t.Errorf("have error %v, want error %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func generate(t *testing.T) []byte {
+	t.Helper()
+	s, err := walk(nil, "../../testdata/schema/declarative/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	header := []string{"Code generated by \"admgenddmstatus\"; DO NOT EDIT."}
+	if err = jenGo("ddm", "StatusItems", s, header, admgen.Revision{Tag: "test"}, &b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestGenerated(t *testing.T) {
+	generated := generate(t)
+	gentest.Golden(t, "testdata/status.go.golden", generated)
+	gentest.Run(t, map[string][]byte{
+		"status.go":      generated,
+		"status_test.go": gentest.ReadFile(t, "testdata/status_test.go"),
+	})
+}
+
+func TestGeneratedDDM(t *testing.T) {
+	// the output of all of the declarative generators in one package
+	const schema = "../../testdata/schema/declarative/"
+	gentest.Run(t, map[string][]byte{
+		"status.go": generate(t),
+		"proto.go":  gentest.Generate(t, "../admgenddmproto", "-pkg", "ddm", "-schema-version", "test", schema+"protocol"),
+		"decls.go":  gentest.Generate(t, "../admgenddm", "-pkg", "ddm", "-schema-version", "test", schema+"declarations"),
+		"refs.go":   gentest.Generate(t, "../admgenddmrefs", "-pkg", "ddm", "-schema-version", "test", schema+"declarations"),
+	})
+}
@@ -0,0 +1,93 @@
+package ddm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMergeStatusItems(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		dst, src, want string
+	}{
+		{"empty src", `{"a": 1}`, `{}`, `{"a": 1}`},
+		{"new key", `{"a": 1}`, `{"b": 2}`, `{"a": 1, "b": 2}`},
+		{"replace scalar", `{"a": 1}`, `{"a": 2}`, `{"a": 2}`},
+		{"merge objects", `{"d": {"x": 1, "y": 2}}`, `{"d": {"y": 3, "z": 4}}`, `{"d": {"x": 1, "y": 3, "z": 4}}`},
+		{"replace arrays", `{"a": [1, 2]}`, `{"a": [3]}`, `{"a": [3]}`},
+		{"replace arrays without identifiers", `{"a": [{"identifier": "x"}]}`, `{"a": [{"identifier": "y"}, {"v": 1}]}`, `{"a": [{"identifier": "y"}, {"v": 1}]}`},
+		{
+			"merge arrays by identifier",
+			`{"a": [{"identifier": "x", "v": 1}, {"identifier": "y", "v": 1}]}`,
+			`{"a": [{"identifier": "y", "v": 2}, {"identifier": "z", "v": 1}]}`,
+			`{"a": [{"identifier": "x", "v": 1}, {"identifier": "y", "v": 2}, {"identifier": "z", "v": 1}]}`,
+		},
+		{
+			"remove array items",
+			`{"d": {"a": [{"identifier": "x"}, {"identifier": "y"}]}}`,
+			`{"d": {"a": [{"identifier": "x", "_removed": true}, {"identifier": "q", "_removed": true}]}}`,
+			`{"d": {"a": [{"identifier": "y"}]}}`,
+		},
+		{"new identified array", `{}`, `{"a": [{"identifier": "x"}, {"identifier": "y", "_removed": true}]}`, `{"a": [{"identifier": "x"}]}`},
+		{"empty array", `{"a": [{"identifier": "x"}]}`, `{"a": []}`, `{"a": [{"identifier": "x"}]}`},
+		{"new empty array", `{}`, `{"a": []}`, `{"a": []}`},
+		{"object replaces scalar", `{"a": 1}`, `{"a": {"b": 2}}`, `{"a": {"b": 2}}`},
+		{"scalar replaces object", `{"a": {"b": 2}}`, `{"a": 1}`, `{"a": 1}`},
+		{"null replaces", `{"a": {"b": 2}}`, `{"a": null}`, `{"a": null}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := decode(t, tc.dst)
+			MergeStatusItems(dst, decode(t, tc.src))
+			if want := decode(t, tc.want); !reflect.DeepEqual(dst, want) {
+				t.Errorf("have %v, want %v", dst, want)
+			}
+		})
+	}
+}
+
+func TestMergeStatusJSON(t *testing.T) {
+	state := make(map[string]interface{})
+	for _, report := range []string{
+		`{"device": {"operating-system": {"version": "17.0", "build-version": "21A1"}}, "device.unknown": {"x": 1}}`,
+		`{"device": {"operating-system": {"version": "17.1"}, "model": {"family": "iPhone"}}}`,
+	} {
+		if err := MergeStatusJSON(state, []byte(report)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MergeStatusJSON(state, []byte(`[]`)); err == nil {
+		t.Error("expected error")
+	}
+
+	// unknown status items are kept
+	if v, ok := LookupStatusItem(state, "device.model.family"); !ok || v != "iPhone" {
+		t.Errorf("device.model.family: have %v %v", v, ok)
+	}
+	if _, ok := state["device.unknown"]; !ok {
+		t.Error("device.unknown dropped")
+	}
+	if v, ok := LookupStatusItem(state, StatusItemDeviceOperatingSystemBuildVersion); !ok || v != "21A1" {
+		t.Errorf("build-version: have %v %v", v, ok)
+	}
+
+	r, err := DecodeStatusItems(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := r.Device.OperatingSystem.Version; v == nil || *v != "17.1" {
+		t.Errorf("version: have %v", v)
+	}
+	if v := r.Device.OperatingSystem.BuildVersion; v == nil || *v != "21A1" {
+		t.Errorf("build-version: have %v", v)
+	}
+}
@@ -0,0 +1,146 @@
+package admgen
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Generator is the command line handling shared by the generators that
+// read a single schema directory: the common flags, opening the schema
+// source and overlay, recording the schema revision, and checking or
+// writing the output. Errors are printed and exit the program.
+type Generator struct {
+	// Name is the command name recorded in the generated header.
+	Name string
+
+	Pkg           *string
+	Out           *string
+	OverlayFile   *string
+	Check         *bool
+	Src           *string
+	SchemaVersion *string
+
+	// Set by Open.
+	Dir      string
+	Source   *Source
+	Overlay  *Overlay
+	Revision Revision
+}
+
+// NewGenerator defines the common flags of the generator name on the
+// default flag set. Command specific flags may be defined before Parse.
+func NewGenerator(name string) *Generator {
+	return &Generator{
+		Name:          name,
+		Pkg:           flag.String("pkg", "main", "Name of generated package"),
+		Out:           flag.String("o", "-", "output filename; \"-\" for stdout"),
+		OverlayFile:   flag.String("overlay", "", "YAML file of schema patches to apply before generating"),
+		Check:         flag.Bool("check", false, "check that the output file is up to date; print a diff and exit non-zero if not"),
+		Src:           flag.String("src", "", "read YAML from a release archive (.zip, .tar.gz) or git ref (<repo-dir>@<ref>)"),
+		SchemaVersion: flag.String("schema-version", "", "schema revision (commit or tag) to record; read from the schema git checkout if empty"),
+	}
+}
+
+// Fatalf prints an error and exits with code.
+func Fatalf(code int, format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ERROR: "+format+"\n", a...)
+	os.Exit(code)
+}
+
+// Warnf prints a warning.
+func Warnf(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "WARNING: "+format+"\n", a...)
+}
+
+// Parse parses the command line and checks the common flags.
+func (g *Generator) Parse() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <yaml-dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(flag.Args()) != 1 {
+		Fatalf(2, "must specify exactly one path to yaml files")
+	}
+	g.Dir = flag.Args()[0]
+
+	if *g.Check && *g.Out == "-" {
+		Fatalf(2, "-check requires an output file")
+	}
+}
+
+// Open opens the schema source, loads the overlay (merged onto base, which
+// may be nil), and determines the schema revision.
+func (g *Generator) Open(base *Overlay) {
+	g.Overlay = base
+	if *g.OverlayFile != "" {
+		overlay, err := LoadOverlay(*g.OverlayFile)
+		if err != nil {
+			Fatalf(2, "loading overlay: %v", err)
+		}
+		g.Overlay = g.Overlay.Merge(overlay)
+	}
+
+	var err error
+	g.Source, err = OpenSource(*g.Src)
+	if err != nil {
+		Fatalf(2, "opening source: %v", err)
+	}
+
+	g.Revision = Revision{Commit: *g.SchemaVersion}
+	if g.Revision.Commit == "" && *g.Src != "" {
+		g.Revision = g.Source.Revision
+	} else if g.Revision.Commit == "" {
+		g.Revision, err = GitRevision(g.Dir)
+		if err != nil && !errors.Is(err, ErrNoRepository) {
+			Warnf("reading schema revision: %v", err)
+		}
+	}
+}
+
+// Summary describes the schema input of files YAML files with content hash.
+func (g *Generator) Summary(files int, hash string) string {
+	return fmt.Sprintf("%s (%d files, %s)", filepath.Base(g.Dir), files, hash)
+}
+
+// Header warns about unused overlay patches and returns the generated file
+// header recording the schema revision, the input summary, and the applied
+// patches.
+func (g *Generator) Header(summary string) []string {
+	for _, name := range g.Overlay.Unused() {
+		Warnf("patch not applied: %s", name)
+	}
+
+	header := []string{"Code generated by \"" + g.Name + "\"; DO NOT EDIT."}
+	if g.Revision.Version() != "" {
+		header = append(header, "Schema: "+g.Revision.String())
+	}
+	header = append(header, "Source: "+summary)
+	if patches := g.Overlay.Applied(); len(patches) >= 1 {
+		header = append(header, "Patches: "+strings.Join(patches, ", "))
+	}
+	return header
+}
+
+// Finish checks output against the output file if -check was given, or
+// otherwise writes it.
+func (g *Generator) Finish(output []byte) {
+	if *g.Check {
+		ok, err := Check(*g.Out, output, os.Stdout)
+		if err != nil {
+			Fatalf(2, "checking output file: %v", err)
+		} else if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := WriteOutput(*g.Out, output); err != nil {
+		Fatalf(2, "writing output file: %v", err)
+	}
+}
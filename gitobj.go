@@ -0,0 +1,586 @@
+package admgen
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gitRepo reads objects and refs directly from a git directory without
+// running git. Loose objects and version 2 pack indexes are supported.
+type gitRepo struct {
+	gitDir    string
+	commonDir string
+
+	packs []*gitPack
+}
+
+// openGitRepo opens the repository at dir: a worktree containing a .git
+// directory (or file) or a bare repository.
+func openGitRepo(dir string) (*gitRepo, error) {
+	if isBareRepo(dir) {
+		return newGitRepo(dir, dir)
+	}
+	root, gitDir, commonDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if abs, _ := filepath.Abs(dir); root != abs {
+		return nil, fmt.Errorf("%s is not the root of a git repository", dir)
+	}
+	return newGitRepo(gitDir, commonDir)
+}
+
+// newGitRepo opens the objects of the git directory gitDir whose refs
+// and objects are in commonDir.
+func newGitRepo(gitDir, commonDir string) (*gitRepo, error) {
+	r := &gitRepo{gitDir: gitDir, commonDir: commonDir}
+	idxs, err := filepath.Glob(filepath.Join(r.commonDir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range idxs {
+		p, err := openGitPack(strings.TrimSuffix(idx, ".idx"))
+		if err != nil {
+			r.close()
+			return nil, fmt.Errorf("opening pack %s: %w", filepath.Base(idx), err)
+		}
+		r.packs = append(r.packs, p)
+	}
+	return r, nil
+}
+
+// isBareRepo reports whether dir looks like a bare git repository.
+func isBareRepo(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *gitRepo) close() {
+	for _, p := range r.packs {
+		p.f.Close()
+	}
+}
+
+// readObject reads the object hash returning its type and contents.
+func (r *gitRepo) readObject(hash string) (string, []byte, error) {
+	typ, data, err := readLooseObject(r.commonDir, hash)
+	if !errors.Is(err, os.ErrNotExist) {
+		return typ, data, err
+	}
+	for _, p := range r.packs {
+		if off, ok := p.find(hash); ok {
+			return p.readAt(r, off)
+		}
+	}
+	return "", nil, fmt.Errorf("object not found: %s", hash)
+}
+
+// readLooseObject reads the loose object hash.
+func readLooseObject(gitDir, hash string) (string, []byte, error) {
+	if len(hash) != 40 {
+		return "", nil, fmt.Errorf("invalid object hash: %s", hash)
+	}
+	f, err := os.Open(filepath.Join(gitDir, "objects", hash[:2], hash[2:]))
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+	header, data, ok := bytes.Cut(b, []byte{0})
+	typ, _, _ := strings.Cut(string(header), " ")
+	if !ok || typ == "" {
+		return "", nil, fmt.Errorf("invalid object header: %s", hash)
+	}
+	return typ, data, nil
+}
+
+// peel follows tag objects from hash until an object of type typ.
+func (r *gitRepo) peel(hash, typ string) (string, error) {
+	for {
+		objType, data, err := r.readObject(hash)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case objType == typ:
+			return hash, nil
+		case objType == "tag":
+			hash = header(data, "object")
+		case objType == "commit" && typ == "tree":
+			hash = header(data, "tree")
+		default:
+			return "", fmt.Errorf("%s is a %s, not a %s", hash, objType, typ)
+		}
+	}
+}
+
+// peelTags follows tag objects from hash returning the first object
+// which isn't a tag. The object itself isn't read.
+func (r *gitRepo) peelTags(hash string) string {
+	for {
+		typ, data, err := r.readObject(hash)
+		if err != nil || typ != "tag" {
+			return hash
+		}
+		hash = header(data, "object")
+	}
+}
+
+// header returns the value of the first header line named name of a
+// commit or tag object.
+func header(data []byte, name string) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		if v := strings.TrimPrefix(line, name+" "); v != line {
+			return v
+		}
+	}
+	return ""
+}
+
+// resolve finds the commit named by rev: a (possibly abbreviated) object
+// hash or a ref name, optionally followed by a reflog selector "@{n}".
+func (r *gitRepo) resolve(rev string) (string, error) {
+	var n int
+	if i := strings.Index(rev, "@{"); i >= 0 && strings.HasSuffix(rev, "}") {
+		var err error
+		n, err = strconv.Atoi(rev[i+2 : len(rev)-1])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("unsupported reflog selector: %s", rev[i:])
+		}
+		rev = rev[:i]
+		if rev == "" {
+			rev = "HEAD"
+		}
+	}
+
+	if n == 0 && isHex(rev) && len(rev) >= 4 && len(rev) <= 40 {
+		if hash, err := r.expand(rev); err == nil {
+			return r.peel(hash, "commit")
+		} else if len(rev) == 40 {
+			return "", err
+		}
+	}
+
+	ref, hash, err := r.dwim(rev)
+	if err != nil {
+		return "", err
+	}
+	if n > 0 {
+		if hash, err = r.reflog(ref, n); err != nil {
+			return "", err
+		}
+	}
+	return r.peel(hash, "commit")
+}
+
+// dwim finds the ref named by name in the order git does.
+func (r *gitRepo) dwim(name string) (ref, hash string, err error) {
+	for _, ref = range []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	} {
+		hash, err = r.readRef(ref)
+		if err == nil {
+			return ref, hash, nil
+		} else if !errors.Is(err, errRefNotFound) {
+			return "", "", err
+		}
+	}
+	return "", "", fmt.Errorf("unknown revision: %s", name)
+}
+
+var errRefNotFound = errors.New("ref not found")
+
+// readRef resolves the (possibly symbolic) ref to an object hash.
+func (r *gitRepo) readRef(ref string) (string, error) {
+	for i := 0; i < 10; i++ {
+		dir := r.commonDir
+		if !strings.HasPrefix(ref, "refs/") {
+			// HEAD and other pseudo refs are per-worktree
+			dir = r.gitDir
+		}
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err == nil {
+			v := strings.TrimSpace(string(b))
+			if target := strings.TrimPrefix(v, "ref: "); target != v {
+				ref = target
+				continue
+			}
+			return v, nil
+		} else if !errors.Is(err, os.ErrNotExist) && !isDirErr(err) {
+			return "", err
+		}
+		refs, err := readPackedRefs(r.commonDir)
+		if err != nil {
+			return "", err
+		}
+		for _, p := range refs {
+			if p.name == ref {
+				return p.hash, nil
+			}
+		}
+		return "", errRefNotFound
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs: %s", ref)
+}
+
+// isDirErr reports whether err is from reading a directory as a file,
+// e.g. the "refs/heads" directory for a ref named "heads".
+func isDirErr(err error) bool {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		if fi, statErr := os.Stat(pe.Path); statErr == nil && fi.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// reflog returns the value of ref n changes ago from its reflog.
+func (r *gitRepo) reflog(ref string, n int) (string, error) {
+	dir := r.commonDir
+	if !strings.HasPrefix(ref, "refs/") {
+		dir = r.gitDir
+	}
+	f, err := os.Open(filepath.Join(dir, "logs", filepath.FromSlash(ref)))
+	if err != nil {
+		return "", fmt.Errorf("reading reflog of %s: %w", ref, err)
+	}
+	defer f.Close()
+	var entries []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// "<old> <new> <committer> <timestamp> <tz>\t<message>"
+		if fields := strings.Fields(s.Text()); len(fields) >= 2 {
+			entries = append(entries, fields[1])
+		}
+	}
+	if err = s.Err(); err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("reflog of %s has only %d entries", ref, len(entries))
+	}
+	return entries[len(entries)-1-n], nil
+}
+
+// expand finds the full object hash of the abbreviated hash prefix.
+func (r *gitRepo) expand(prefix string) (string, error) {
+	found := make(map[string]bool)
+	if len(prefix) >= 2 {
+		entries, _ := os.ReadDir(filepath.Join(r.commonDir, "objects", prefix[:2]))
+		for _, e := range entries {
+			if hash := prefix[:2] + e.Name(); strings.HasPrefix(hash, prefix) && len(hash) == 40 {
+				found[hash] = true
+			}
+		}
+	}
+	for _, p := range r.packs {
+		for _, hash := range p.withPrefix(prefix) {
+			found[hash] = true
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("object not found: %s", prefix)
+	case 1:
+		for hash := range found {
+			return hash, nil
+		}
+	}
+	return "", fmt.Errorf("ambiguous object hash: %s", prefix)
+}
+
+// isHex reports whether s only contains lowercase hex digits.
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// readTree reads the blobs of the tree hash (recursively) into m with
+// names prefixed by dir.
+func (r *gitRepo) readTree(hash, dir string, m memFS) error {
+	typ, data, err := r.readObject(hash)
+	if err != nil {
+		return err
+	} else if typ != "tree" {
+		return fmt.Errorf("%s is a %s, not a tree", hash, typ)
+	}
+	for len(data) > 0 {
+		// "<mode> <name>\x00<20 byte hash>"
+		i := bytes.IndexByte(data, 0)
+		if i < 0 || len(data) < i+21 {
+			return fmt.Errorf("invalid tree: %s", hash)
+		}
+		mode, name, _ := strings.Cut(string(data[:i]), " ")
+		entry := hex.EncodeToString(data[i+1 : i+21])
+		data = data[i+21:]
+		switch mode {
+		case "40000":
+			if err = r.readTree(entry, dir+name+"/", m); err != nil {
+				return err
+			}
+		case "100644", "100755":
+			if typ, m[dir+name], err = r.readObject(entry); err != nil {
+				return err
+			} else if typ != "blob" {
+				return fmt.Errorf("%s is a %s, not a blob", entry, typ)
+			}
+		}
+		// symlinks and submodules are skipped
+	}
+	return nil
+}
+
+// gitPack is a pack file and its index.
+type gitPack struct {
+	f *os.File
+
+	names   []byte // sorted 20 byte object names
+	offsets []byte // 4 byte offsets
+	large   []byte // 8 byte offsets
+}
+
+// pack object types
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var packTypes = map[byte]string{objCommit: "commit", objTree: "tree", objBlob: "blob", objTag: "tag"}
+
+// openGitPack opens the pack and index at base (without extension).
+func openGitPack(base string) (*gitPack, error) {
+	idx, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:8], []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}) {
+		return nil, errors.New("unsupported pack index version")
+	}
+	n := int(binary.BigEndian.Uint32(idx[8+255*4:]))
+	namesAt := 8 + 256*4
+	offsetsAt := namesAt + n*20 + n*4 // skip the CRCs
+	largeAt := offsetsAt + n*4
+	if len(idx) < largeAt {
+		return nil, errors.New("truncated pack index")
+	}
+	f, err := os.Open(base + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return &gitPack{
+		f:       f,
+		names:   idx[namesAt : namesAt+n*20],
+		offsets: idx[offsetsAt:largeAt],
+		large:   idx[largeAt:],
+	}, nil
+}
+
+func (p *gitPack) count() int { return len(p.names) / 20 }
+
+func (p *gitPack) name(i int) []byte { return p.names[i*20 : i*20+20] }
+
+// find returns the pack offset of the object hash.
+func (p *gitPack) find(hash string) (int64, bool) {
+	b, err := hex.DecodeString(hash)
+	if err != nil || len(b) != 20 {
+		return 0, false
+	}
+	i := sort.Search(p.count(), func(i int) bool { return bytes.Compare(p.name(i), b) >= 0 })
+	if i >= p.count() || !bytes.Equal(p.name(i), b) {
+		return 0, false
+	}
+	off := int64(binary.BigEndian.Uint32(p.offsets[i*4:]))
+	if off&0x80000000 != 0 {
+		j := int(off & 0x7fffffff)
+		if len(p.large) < j*8+8 {
+			return 0, false
+		}
+		off = int64(binary.BigEndian.Uint64(p.large[j*8:]))
+	}
+	return off, true
+}
+
+// withPrefix returns the object hashes starting with the hex prefix.
+func (p *gitPack) withPrefix(prefix string) []string {
+	i := sort.Search(p.count(), func(i int) bool { return hex.EncodeToString(p.name(i)) >= prefix })
+	var hashes []string
+	for ; i < p.count(); i++ {
+		hash := hex.EncodeToString(p.name(i))
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// readAt reads the object at offset off of the pack. Deltas are applied
+// to their base object which may be in other packs of r.
+func (p *gitPack) readAt(r *gitRepo, off int64) (string, []byte, error) {
+	br := bufio.NewReader(io.NewSectionReader(p.f, off, 1<<62))
+	c, err := br.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	objType := (c >> 4) & 7
+	for c&0x80 != 0 {
+		// the rest of the inflated size isn't needed
+		if c, err = br.ReadByte(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var baseType string
+	var base []byte
+	switch objType {
+	case objOfsDelta:
+		c, err = br.ReadByte()
+		if err != nil {
+			return "", nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return "", nil, err
+			}
+			rel = (rel+1)<<7 | int64(c&0x7f)
+		}
+		if baseType, base, err = p.readAt(r, off-rel); err != nil {
+			return "", nil, err
+		}
+	case objRefDelta:
+		b := make([]byte, 20)
+		if _, err = io.ReadFull(br, b); err != nil {
+			return "", nil, err
+		}
+		if baseType, base, err = r.readObject(hex.EncodeToString(b)); err != nil {
+			return "", nil, err
+		}
+	}
+
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+	if base != nil {
+		data, err = applyDelta(base, data)
+		return baseType, data, err
+	}
+	typ, ok := packTypes[objType]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown pack object type %d", objType)
+	}
+	return typ, data, nil
+}
+
+// applyDelta applies the git delta to base.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errInvalid := errors.New("invalid delta")
+	varint := func() (int, bool) {
+		var v, shift int
+		for len(delta) > 0 {
+			c := delta[0]
+			delta = delta[1:]
+			v |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+	baseSize, ok := varint()
+	if !ok || baseSize != len(base) {
+		return nil, errInvalid
+	}
+	size, ok := varint()
+	if !ok {
+		return nil, errInvalid
+	}
+	out := make([]byte, 0, size)
+	for len(delta) > 0 {
+		c := delta[0]
+		delta = delta[1:]
+		if c&0x80 == 0 {
+			// insert the next c bytes
+			if c == 0 || int(c) > len(delta) {
+				return nil, errInvalid
+			}
+			out = append(out, delta[:c]...)
+			delta = delta[c:]
+			continue
+		}
+		// copy from base: bits 0-3 are offset bytes, bits 4-6 size bytes
+		var offset, n int
+		for i := 0; i < 7; i++ {
+			if c&(1<<i) == 0 {
+				continue
+			}
+			if len(delta) < 1 {
+				return nil, errInvalid
+			}
+			if i < 4 {
+				offset |= int(delta[0]) << (8 * i)
+			} else {
+				n |= int(delta[0]) << (8 * (i - 4))
+			}
+			delta = delta[1:]
+		}
+		if n == 0 {
+			n = 0x10000
+		}
+		if offset+n > len(base) {
+			return nil, errInvalid
+		}
+		out = append(out, base[offset:offset+n]...)
+	}
+	if len(out) != size {
+		return nil, errInvalid
+	}
+	return out, nil
+}
@@ -0,0 +1,104 @@
+// Package gentest supports testing the code generated by the admgen
+// commands: comparing it to golden files and building, vetting, and
+// testing it in a temporary module.
+package gentest
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/admgen"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Golden compares generated to the golden file at path. With -update the
+// golden file is written instead.
+func Golden(t testing.TB, path string, generated []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, generated, 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := admgen.Diff(path, "generated", want, generated); diff != "" {
+		t.Errorf("generated code differs from golden file (update with -update):\n%s", diff)
+	}
+}
+
+// Run writes files (slash-separated file name to contents) into a
+// temporary module and runs go vet on the package in its root. If any of
+// the files are tests they are run too. A go.mod is written unless files
+// has one. The test is skipped if the go command is not found.
+func Run(t testing.TB, files map[string][]byte) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	if _, ok := files["go.mod"]; !ok {
+		files["go.mod"] = []byte("module gentest\n\ngo 1.19\n")
+	}
+	tests := false
+	for name, b := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0666); err != nil {
+			t.Fatal(err)
+		}
+		tests = tests || strings.HasSuffix(name, "_test.go")
+	}
+	run(t, dir, "vet", ".")
+	if tests {
+		run(t, dir, "test", ".")
+	}
+}
+
+func run(t testing.TB, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// Generate runs the generator command in the package directory dir with
+// args and returns its output.
+func Generate(t testing.TB, dir string, args ...string) []byte {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	cmd := exec.Command("go", append([]string{"run", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go run %s: %v\n%s", dir, err, stderr.String())
+	}
+	return out
+}
+
+// ReadFile reads the named file for Run.
+func ReadFile(t testing.TB, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
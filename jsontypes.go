@@ -0,0 +1,245 @@
+package admgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// SchemaKey represents the "key" type of the Apple Device Management YAML.
+type SchemaKey struct {
+	Key       string      `yaml:"key"`
+	Type      string      `yaml:"type"`
+	Presence  string      `yaml:"presence,omitempty"`
+	SubKeys   []SchemaKey `yaml:"subkeys,omitempty"`
+	Content   string      `yaml:"content"`
+	RangeList []string    `yaml:"rangelist,omitempty"`
+	// AssetTypes are the declaration types a key may reference.
+	AssetTypes []string `yaml:"assettypes,omitempty"`
+	// CombineType is how the values of multiple declarations combine.
+	CombineType string `yaml:"combinetype,omitempty"`
+}
+
+// Pointer reports whether the generated Go field for k is a pointer.
+// Optional keys are pointers unless their type can already be nil.
+func (k SchemaKey) Pointer() bool {
+	if k.Presence == "required" {
+		return false
+	}
+	switch k.Type {
+	case "<array>", "<data>", "<any>":
+		return false
+	case "<dictionary>":
+		return len(k.SubKeys) > 0
+	}
+	return true
+}
+
+// jsonField is a generated struct field.
+type jsonField struct {
+	name     string
+	required bool
+	// name of the generated struct type, if the field is a struct
+	structType string
+	// the field is a slice or map
+	collection bool
+	typ        jen.Code
+}
+
+// JSONTypes generates Go types with JSON struct tags from schema keys.
+// Nested dictionaries become struct types named by appending the
+// (normalized) key name to the parent type name.
+type JSONTypes struct {
+	file    *jen.File
+	structs map[string][]jsonField
+}
+
+// NewJSONTypes creates a new JSON type generator that adds types to file.
+func NewJSONTypes(file *jen.File) *JSONTypes {
+	return &JSONTypes{file: file, structs: make(map[string][]jsonField)}
+}
+
+// Defined reports whether a struct type named name has been generated.
+func (t *JSONTypes) Defined(name string) bool {
+	_, ok := t.structs[name]
+	return ok
+}
+
+// Struct generates a struct type named name with a field for each key.
+// Nested dictionaries are generated as struct types too. It is an error
+// if name or the name of a nested struct type has already been generated,
+// for example when a nested type of one struct has the same name as
+// another struct.
+func (t *JSONTypes) Struct(name, comment string, keys []SchemaKey) error {
+	if t.Defined(name) {
+		return fmt.Errorf("duplicate type name %s", name)
+	}
+	t.structs[name] = nil
+	var fields []jen.Code
+	var info []jsonField
+	for _, k := range keys {
+		s, f, fieldComment, err := t.fieldType(name, k)
+		if err != nil {
+			return err
+		}
+		f.name = FieldName(k.Key)
+		tag := k.Key
+		if !f.required {
+			tag += ",omitempty"
+		}
+		field := jen.Id(f.name).Add(s).Tag(map[string]string{"json": tag})
+		if fieldComment != "" {
+			field.Comment(fieldComment)
+		}
+		fields = append(fields, field)
+		info = append(info, f)
+	}
+	t.structs[name] = info
+	if comment != "" {
+		t.file.Comment(comment)
+	}
+	t.file.Type().Id(name).Struct(fields...)
+	return nil
+}
+
+// fieldType returns the Go type of key k as a field of struct parent.
+func (t *JSONTypes) fieldType(parent string, k SchemaKey) (s *jen.Statement, f jsonField, comment string, err error) {
+	f.required = k.Presence == "required"
+	s, comment, err = t.Type(parent+FieldName(k.Key), k)
+	if err != nil {
+		return
+	}
+	switch k.Type {
+	case "<dictionary>":
+		if len(k.SubKeys) > 0 {
+			f.structType = parent + FieldName(k.Key)
+		} else {
+			f.collection = true
+		}
+	case "<array>", "<data>":
+		f.collection = true
+	}
+	if k.Pointer() {
+		s = jen.Op("*").Add(s)
+	}
+	f.typ = s
+	if len(k.RangeList) >= 1 {
+		if comment != "" {
+			comment += ", "
+		}
+		begin := "supported value"
+		if len(k.RangeList) > 1 {
+			begin += "s"
+		}
+		comment += begin + ": " + strings.Join(k.RangeList, ", ")
+	}
+	return
+}
+
+// Type returns the (non-pointer) Go type of key k. Any struct types
+// needed are generated with name.
+func (t *JSONTypes) Type(name string, k SchemaKey) (s *jen.Statement, comment string, err error) {
+	switch k.Type {
+	case "<string>":
+		return jen.String(), "", nil
+	case "<integer>":
+		return jen.Int(), "", nil
+	case "<boolean>":
+		return jen.Bool(), "", nil
+	case "<real>":
+		return jen.Float64(), "", nil
+	case "<data>":
+		return jen.Index().Byte(), "", nil
+	case "<date>":
+		return jen.Qual("time", "Time"), "", nil
+	case "<any>":
+		return jen.Interface(), "", nil
+	case "<dictionary>":
+		if len(k.SubKeys) < 1 {
+			return jen.Map(jen.String()).Interface(), "no keys defined in schema", nil
+		}
+		if err = t.Struct(name, "", k.SubKeys); err != nil {
+			return nil, "", err
+		}
+		return jen.Id(name), "", nil
+	case "<array>":
+		if len(k.SubKeys) < 1 {
+			return jen.Index().Interface(), "missing array keys in schema", nil
+		}
+		item := k.SubKeys[0]
+		for _, other := range k.SubKeys[1:] {
+			if other.Type != item.Type {
+				return jen.Index().Interface(), "mismatched array types in schema", nil
+			}
+		}
+		s, comment, err = t.Type(name+"Item", item)
+		if err != nil {
+			return nil, "", err
+		}
+		return jen.Index().Add(s), comment, nil
+	}
+	return jen.Interface(), "unknown type: " + k.Type, nil
+}
+
+// literal returns a composite literal of struct name with its required
+// slices, maps, and structs initialized. Required collections are
+// initialized so that they encode as empty JSON arrays and objects
+// rather than null.
+func (t *JSONTypes) literal(name string) *jen.Statement {
+	d := jen.Dict{}
+	for _, f := range t.structs[name] {
+		if !f.required {
+			continue
+		}
+		if f.structType != "" && t.initialized(f.structType) {
+			d[jen.Id(f.name)] = t.literal(f.structType)
+		} else if f.collection {
+			d[jen.Id(f.name)] = jen.Add(f.typ).Values()
+		}
+	}
+	return jen.Id(name).Values(d)
+}
+
+// initialized reports whether struct name has any required fields
+// initialized by literal.
+func (t *JSONTypes) initialized(name string) bool {
+	for _, f := range t.structs[name] {
+		if f.required && (f.collection || (f.structType != "" && t.initialized(f.structType))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Constructor generates a New<name> function for the struct type name
+// which initializes its required fields.
+func (t *JSONTypes) Constructor(name string) {
+	t.file.Comment("New" + name + " creates a new " + name + " with its required fields initialized.")
+	t.file.Func().Id("New" + name).Params().Op("*").Id(name).Block(
+		jen.Return(jen.Op("&").Add(t.literal(name))),
+	)
+}
+
+// FieldName converts a schema key name into an exported Go identifier.
+func FieldName(s string) string {
+	var b strings.Builder
+	upper := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			if upper {
+				c -= 'a' - 'A'
+			}
+			fallthrough
+		case 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			b.WriteByte(c)
+			upper = false
+		default:
+			// start a new word after any separator
+			upper = true
+		}
+	}
+	return b.String()
+}
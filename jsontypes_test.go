@@ -0,0 +1,53 @@
+package admgen
+
+import (
+	"testing"
+
+	"github.com/dave/jennifer/jen"
+)
+
+func TestFieldName(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"InstallAsManaged", "InstallAsManaged"},
+		{"iTunesStoreID", "ITunesStoreID"},
+		{"configuration.passcode.settings", "ConfigurationPasscodeSettings"},
+		{"serial-number", "SerialNumber"},
+		{"_app", "App"},
+		{"MDM_Options 2", "MDMOptions2"},
+		{"", ""},
+	} {
+		if have := FieldName(tc.in); have != tc.want {
+			t.Errorf("%q: have %q, want %q", tc.in, have, tc.want)
+		}
+	}
+}
+
+func TestStructDuplicate(t *testing.T) {
+	nested := []SchemaKey{{Key: "Bar", Type: "<dictionary>", SubKeys: []SchemaKey{{Key: "A", Type: "<string>"}}}}
+	for _, tc := range []struct {
+		name    string
+		structs []string
+		keys    [][]SchemaKey
+		err     bool
+	}{
+		{"distinct", []string{"Foo", "Baz"}, [][]SchemaKey{nested, nested}, false},
+		{"same name", []string{"Foo", "Foo"}, [][]SchemaKey{nil, nil}, true},
+		{"nested then top-level", []string{"Foo", "FooBar"}, [][]SchemaKey{nested, nil}, true},
+		{"top-level then nested", []string{"FooBar", "Foo"}, [][]SchemaKey{nil, nested}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			types := NewJSONTypes(jen.NewFile("test"))
+			var err error
+			for i, name := range tc.structs {
+				if err = types.Struct(name, "", tc.keys[i]); err != nil {
+					break
+				}
+			}
+			if tc.err != (err != nil) {
+				t.Errorf("have error %v, want error %v", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Package admgen contains shared support for the admgen code generators.
+package admgen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Patch is a small change to an Apple Device Management YAML schema file.
+// Patches are used to correct known errata in Apple's schema data.
+type Patch struct {
+	// Name identifies the patch in generated output.
+	Name string `yaml:"name"`
+	// File is the filename (or glob) of the schema YAML to patch. It is
+	// matched against as many trailing path elements as it contains.
+	File string `yaml:"file"`
+	// Path is the dot-separated path of the patched schema item. The first
+	// element names a top-level section (e.g. "payloadkeys"), any further
+	// elements are key names descending through subkeys (or the codes of
+	// MDM error definitions).
+	Path string `yaml:"path"`
+
+	// Add are keys to add to the subkeys of the key at Path, or to the
+	// section itself if Path only names a section.
+	Add []yaml.Node `yaml:"add,omitempty"`
+	// Set are fields to set (or replace) on the key at Path.
+	// For example presence or type.
+	Set yaml.Node `yaml:"set,omitempty"`
+	// RangeList are values to append to the rangelist of the key at Path.
+	RangeList []string `yaml:"rangelist,omitempty"`
+}
+
+// Overlay is a set of patches applied to schema files as they are decoded.
+type Overlay struct {
+	Patches []Patch `yaml:"patches"`
+
+	applied map[string]bool
+}
+
+// LoadOverlay reads an overlay YAML file from path.
+func LoadOverlay(path string) (*Overlay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadOverlay(f)
+}
+
+// ReadOverlay reads an overlay YAML document from r.
+func ReadOverlay(r io.Reader) (*Overlay, error) {
+	o := &Overlay{applied: make(map[string]bool)}
+	if err := yaml.NewDecoder(r).Decode(o); err != nil {
+		return nil, fmt.Errorf("decoding overlay: %w", err)
+	}
+	for i, p := range o.Patches {
+		if p.Name == "" || p.File == "" || p.Path == "" {
+			return nil, fmt.Errorf("patch %d: name, file, and path are required", i)
+		}
+	}
+	return o, nil
+}
+
+// Merge returns an overlay with the patches of both o and other.
+func (o *Overlay) Merge(other *Overlay) *Overlay {
+	if o == nil {
+		return other
+	} else if other == nil {
+		return o
+	}
+	patches := append(o.Patches[:len(o.Patches):len(o.Patches)], other.Patches...)
+	return &Overlay{Patches: patches, applied: make(map[string]bool)}
+}
+
+// Decode decodes the YAML in r into v after applying any patches for
+// the schema file name.
+func (o *Overlay) Decode(r io.Reader, name string, v interface{}) error {
+	doc := new(yaml.Node)
+	if err := yaml.NewDecoder(r).Decode(doc); err != nil {
+		return err
+	}
+	if o != nil {
+		for _, p := range o.Patches {
+			if !p.matches(name) {
+				continue
+			}
+			if err := p.apply(doc); errors.Is(err, errNotFound) && p.isGlob() {
+				// glob patches only apply to the files with the path
+				continue
+			} else if err != nil {
+				return fmt.Errorf("applying patch %s: %w", p.Name, err)
+			}
+			o.applied[p.Name] = true
+		}
+	}
+	return doc.Decode(v)
+}
+
+// Applied returns the sorted names of patches that have been applied.
+func (o *Overlay) Applied() []string {
+	if o == nil {
+		return nil
+	}
+	var names []string
+	for name := range o.applied {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Unused returns the names of patches that were never applied.
+func (o *Overlay) Unused() []string {
+	if o == nil {
+		return nil
+	}
+	var names []string
+	for _, p := range o.Patches {
+		if !o.applied[p.Name] {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// isGlob reports whether the File of p is a pattern that may match
+// several schema files.
+func (p *Patch) isGlob() bool {
+	return strings.ContainsAny(p.File, "*?[")
+}
+
+// matches reports whether the schema file name is targeted by p.
+func (p *Patch) matches(name string) bool {
+	elems := strings.Split(filepath.ToSlash(name), "/")
+	if n := strings.Count(p.File, "/") + 1; n < len(elems) {
+		elems = elems[len(elems)-n:]
+	}
+	ok, _ := path.Match(p.File, strings.Join(elems, "/"))
+	return ok
+}
+
+func (p *Patch) apply(doc *yaml.Node) error {
+	target, err := resolve(doc, strings.Split(p.Path, "."))
+	if err != nil {
+		return err
+	}
+	if len(p.Add) > 0 {
+		seq := target
+		if target.Kind == yaml.MappingNode {
+			seq = mapValue(target, "subkeys")
+			if seq == nil {
+				seq = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+				setMapValue(target, "subkeys", seq)
+			}
+		}
+		if seq.Kind != yaml.SequenceNode {
+			return errors.New("add target is not a list of keys")
+		}
+		for i := range p.Add {
+			seq.Content = append(seq.Content, copyNode(&p.Add[i]))
+		}
+	}
+	if p.Set.Kind != 0 || len(p.RangeList) > 0 {
+		if target.Kind != yaml.MappingNode {
+			return errors.New("set target is not a key")
+		}
+	}
+	if p.Set.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(p.Set.Content); i += 2 {
+			setMapValue(target, p.Set.Content[i].Value, copyNode(p.Set.Content[i+1]))
+		}
+	} else if p.Set.Kind != 0 {
+		return errors.New("set is not a mapping")
+	}
+	if len(p.RangeList) > 0 {
+		seq := mapValue(target, "rangelist")
+		if seq == nil {
+			seq = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			setMapValue(target, "rangelist", seq)
+		}
+		for _, v := range p.RangeList {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v})
+		}
+	}
+	return nil
+}
+
+// errNotFound is returned when a patch path is not in a document.
+var errNotFound = errors.New("not found")
+
+// resolve finds the node at elems in doc. The first element is a
+// top-level section and the rest are key names or error codes.
+func resolve(doc *yaml.Node, elems []string) (*yaml.Node, error) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) < 1 {
+		return nil, errors.New("not a YAML document")
+	}
+	n := mapValue(doc.Content[0], elems[0])
+	if n == nil {
+		return nil, fmt.Errorf("section %w: %s", errNotFound, elems[0])
+	}
+	for i, elem := range elems[1:] {
+		if n.Kind == yaml.MappingNode {
+			n = mapValue(n, "subkeys")
+		}
+		var found *yaml.Node
+		if n != nil && n.Kind == yaml.SequenceNode {
+			for _, k := range n.Content {
+				if v := mapValue(k, "key"); v != nil && v.Value == elem {
+					found = k
+					break
+				} else if v := mapValue(k, "code"); v != nil && v.Value == elem {
+					found = k
+					break
+				}
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("key %w: %s", errNotFound, strings.Join(elems[:i+2], "."))
+		}
+		n = found
+	}
+	return n, nil
+}
+
+// copyNode returns a deep copy of n so patches applied to several
+// documents don't share (and later modify) the same nodes.
+func copyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
+
+// mapValue returns the value of key in mapping node n.
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMapValue sets (or replaces) the value of key in mapping node n.
+func setMapValue(n *yaml.Node, key string, v *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = v
+			return
+		}
+	}
+	n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, v)
+}
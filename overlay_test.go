@@ -0,0 +1,135 @@
+package admgen
+
+import (
+	"strings"
+	"testing"
+)
+
+const overlaySchema = `payload:
+  requesttype: Foo
+payloadkeys:
+- key: Bar
+  type: <dictionary>
+  subkeys:
+  - key: Baz
+    type: <string>
+`
+
+type overlayKey struct {
+	Key       string       `yaml:"key"`
+	Type      string       `yaml:"type"`
+	Presence  string       `yaml:"presence"`
+	RangeList []string     `yaml:"rangelist"`
+	SubKeys   []overlayKey `yaml:"subkeys"`
+}
+
+type overlayDoc struct {
+	PayloadKeys []overlayKey `yaml:"payloadkeys"`
+}
+
+func TestPatchMatches(t *testing.T) {
+	for _, tc := range []struct {
+		file, name string
+		want       bool
+	}{
+		{"foo.yaml", "mdm/commands/foo.yaml", true},
+		{"commands/foo.yaml", "mdm/commands/foo.yaml", true},
+		{"checkin/foo.yaml", "mdm/commands/foo.yaml", false},
+		{"*.yaml", "mdm/commands/foo.yaml", true},
+		{"commands/*.yaml", "mdm/commands/foo.yaml", true},
+		{"bar.yaml", "mdm/commands/foo.yaml", false},
+	} {
+		p := &Patch{File: tc.file}
+		if have := p.matches(tc.name); have != tc.want {
+			t.Errorf("%s matches %s: have %v, want %v", tc.file, tc.name, have, tc.want)
+		}
+	}
+}
+
+func TestOverlayDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		overlay string
+		check   func(*overlayDoc) bool
+		wantErr bool
+	}{
+		{
+			name: "add section",
+			overlay: `patches:
+- {name: p, file: foo.yaml, path: payloadkeys, add: [{key: Qux, type: <integer>}]}`,
+			check: func(d *overlayDoc) bool { return len(d.PayloadKeys) == 2 && d.PayloadKeys[1].Key == "Qux" },
+		},
+		{
+			name: "add subkey",
+			overlay: `patches:
+- {name: p, file: foo.yaml, path: payloadkeys.Bar, add: [{key: Qux, type: <integer>}]}`,
+			check: func(d *overlayDoc) bool { return len(d.PayloadKeys[0].SubKeys) == 2 },
+		},
+		{
+			name: "set",
+			overlay: `patches:
+- {name: p, file: foo.yaml, path: payloadkeys.Bar.Baz, set: {presence: required}}`,
+			check: func(d *overlayDoc) bool { return d.PayloadKeys[0].SubKeys[0].Presence == "required" },
+		},
+		{
+			name: "rangelist",
+			overlay: `patches:
+- {name: p, file: foo.yaml, path: payloadkeys.Bar.Baz, rangelist: [A, B]}`,
+			check: func(d *overlayDoc) bool { return len(d.PayloadKeys[0].SubKeys[0].RangeList) == 2 },
+		},
+		{
+			name: "missing path",
+			overlay: `patches:
+- {name: p, file: foo.yaml, path: payloadkeys.Nope, set: {presence: required}}`,
+			wantErr: true,
+		},
+		{
+			name: "missing path glob",
+			overlay: `patches:
+- {name: p, file: "*.yaml", path: payloadkeys.Nope, set: {presence: required}}`,
+			check: func(d *overlayDoc) bool { return d.PayloadKeys[0].Presence == "" },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := ReadOverlay(strings.NewReader(tc.overlay))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := new(overlayDoc)
+			err = o.Decode(strings.NewReader(overlaySchema), "commands/foo.yaml", d)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.check(d) {
+				t.Errorf("unexpected result: %+v", d)
+			}
+		})
+	}
+}
+
+func TestOverlayGlobNodesNotShared(t *testing.T) {
+	o, err := ReadOverlay(strings.NewReader(`patches:
+- {name: add, file: "*.yaml", path: payloadkeys, add: [{key: Qux, type: <integer>}]}
+- {name: set, file: a.yaml, path: payloadkeys.Qux, set: {presence: required}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := new(overlayDoc), new(overlayDoc)
+	if err = o.Decode(strings.NewReader(overlaySchema), "a.yaml", a); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.Decode(strings.NewReader(overlaySchema), "b.yaml", b); err != nil {
+		t.Fatal(err)
+	}
+	if have := a.PayloadKeys[1].Presence; have != "required" {
+		t.Errorf("a.yaml presence: have %q, want %q", have, "required")
+	}
+	if have := b.PayloadKeys[1].Presence; have != "" {
+		t.Errorf("b.yaml presence: have %q, want empty", have)
+	}
+}
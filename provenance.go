@@ -0,0 +1,204 @@
+package admgen
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNoRepository is returned when no git repository could be found.
+var ErrNoRepository = errors.New("no git repository found")
+
+// ErrNotSchemaRepository is returned when the git repository containing
+// the schema is not a checkout of the schema itself. For example when the
+// schema is vendored into another repository.
+var ErrNotSchemaRepository = errors.New("git repository is not a schema checkout")
+
+// schemaDirs are the top-level directories of the schema repository.
+var schemaDirs = map[string]bool{"mdm": true, "declarative": true, "other": true}
+
+// Revision identifies a revision of the device-management schema data.
+type Revision struct {
+	Commit string
+	Tag    string
+}
+
+// Version returns the tag of r if it has one, otherwise the commit.
+func (r Revision) Version() string {
+	if r.Tag != "" {
+		return r.Tag
+	}
+	return r.Commit
+}
+
+// String returns a description of r suitable for generated headers.
+func (r Revision) String() string {
+	if r.Tag != "" && r.Commit != "" {
+		return fmt.Sprintf("%s (%s)", r.Tag, r.Commit)
+	}
+	return r.Version()
+}
+
+// ContentHash returns a short, stable hash of schema file contents.
+func ContentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// GitRevision finds the checked out revision of the git repository
+// containing path. The repository is read directly from the .git
+// directory without running git. The root of the repository must be
+// the schema checkout: path must be within one of its top-level schema
+// directories, otherwise ErrNotSchemaRepository is returned.
+func GitRevision(path string) (Revision, error) {
+	root, gitDir, commonDir, err := findGitDir(path)
+	if err != nil {
+		return Revision{}, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Revision{}, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return Revision{}, err
+	}
+	if top, _, _ := strings.Cut(filepath.ToSlash(rel), "/"); !schemaDirs[top] {
+		return Revision{}, fmt.Errorf("%w: %s (use -schema-version)", ErrNotSchemaRepository, root)
+	}
+	repo, err := newGitRepo(gitDir, commonDir)
+	if err != nil {
+		return Revision{}, err
+	}
+	defer repo.close()
+	rev := Revision{}
+	if rev.Commit, err = repo.readRef("HEAD"); err != nil {
+		return rev, fmt.Errorf("resolving HEAD: %w", err)
+	}
+	rev.Tag, err = findTag(repo, rev.Commit)
+	return rev, err
+}
+
+// findGitDir searches path and its parents for a git directory.
+// The root is the worktree directory containing it.
+func findGitDir(path string) (root, gitDir, commonDir string, err error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		gitDir = filepath.Join(dir, ".git")
+		fi, err := os.Stat(gitDir)
+		if err == nil {
+			if !fi.IsDir() {
+				// worktrees and submodules have a .git file pointing to the git directory
+				b, err := os.ReadFile(gitDir)
+				if err != nil {
+					return "", "", "", err
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(b), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			commonDir = gitDir
+			if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+				commonDir = strings.TrimSpace(string(b))
+				if !filepath.IsAbs(commonDir) {
+					commonDir = filepath.Join(gitDir, commonDir)
+				}
+			}
+			return dir, gitDir, commonDir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", "", ErrNoRepository
+		}
+		dir = parent
+	}
+}
+
+// packedRef is an entry of a packed-refs file.
+type packedRef struct {
+	name   string
+	hash   string
+	peeled string
+}
+
+func readPackedRefs(gitDir string) ([]packedRef, error) {
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var refs []packedRef
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^") && len(refs) > 0:
+			refs[len(refs)-1].peeled = line[1:]
+		default:
+			if hash, name, ok := strings.Cut(line, " "); ok {
+				refs = append(refs, packedRef{name: name, hash: hash})
+			}
+		}
+	}
+	return refs, s.Err()
+}
+
+// findTag returns the name of a tag pointing at commit, if any.
+// If multiple tags point at commit the lexically last is returned.
+func findTag(r *gitRepo, commit string) (string, error) {
+	var tags []string
+	refs, err := readPackedRefs(r.commonDir)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range refs {
+		if strings.HasPrefix(r.name, "refs/tags/") && (r.hash == commit || r.peeled == commit) {
+			tags = append(tags, strings.TrimPrefix(r.name, "refs/tags/"))
+		}
+	}
+	tagsDir := filepath.Join(r.commonDir, "refs", "tags")
+	err = filepath.WalkDir(tagsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		hash := strings.TrimSpace(string(b))
+		if hash != commit {
+			// annotated tags point to a tag object rather than the commit
+			if hash = r.peelTags(hash); hash != commit {
+				return nil
+			}
+		}
+		name, err := filepath.Rel(tagsDir, path)
+		tags = append(tags, filepath.ToSlash(name))
+		return err
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if len(tags) < 1 {
+		return "", nil
+	}
+	sort.Strings(tags)
+	return tags[len(tags)-1], nil
+}
@@ -0,0 +1,142 @@
+package admgen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const (
+	testCommit = "1111111111111111111111111111111111111111"
+	testTagObj = "2222222222222222222222222222222222222222"
+)
+
+// writeFiles writes files (keyed by slash-separated path) under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// looseObject returns the zlib compressed loose object of type typ.
+func looseObject(t *testing.T, typ, content string) string {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write([]byte(typ + " " + strconv.Itoa(len(content)) + "\x00" + content))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestGitRevision(t *testing.T) {
+	annotated := looseObject(t, "tag", "object "+testCommit+"\ntype commit\ntag v2\n")
+	for _, tc := range []struct {
+		name    string
+		files   map[string]string
+		path    string
+		want    Revision
+		wantErr error
+	}{
+		{
+			name:    "no repository",
+			files:   map[string]string{"mdm/commands/a.yaml": ""},
+			path:    "mdm/commands/a.yaml",
+			wantErr: ErrNoRepository,
+		},
+		{
+			name: "detached head",
+			files: map[string]string{
+				".git/HEAD":           testCommit + "\n",
+				"mdm/commands/a.yaml": "",
+			},
+			path: "mdm/commands/a.yaml",
+			want: Revision{Commit: testCommit},
+		},
+		{
+			name: "loose ref and tag",
+			files: map[string]string{
+				".git/HEAD":            "ref: refs/heads/main\n",
+				".git/refs/heads/main": testCommit + "\n",
+				".git/refs/tags/v1":    testCommit + "\n",
+				"declarative/status":   "",
+			},
+			path: "declarative/status",
+			want: Revision{Commit: testCommit, Tag: "v1"},
+		},
+		{
+			name: "packed refs and peeled tag",
+			files: map[string]string{
+				".git/HEAD": "ref: refs/heads/main\n",
+				".git/packed-refs": "# pack-refs with: peeled fully-peeled sorted\n" +
+					testCommit + " refs/heads/main\n" +
+					testTagObj + " refs/tags/v2\n" +
+					"^" + testCommit + "\n",
+				"mdm/commands/a.yaml": "",
+			},
+			path: "mdm/commands",
+			want: Revision{Commit: testCommit, Tag: "v2"},
+		},
+		{
+			name: "annotated loose tag",
+			files: map[string]string{
+				".git/HEAD":         testCommit + "\n",
+				".git/refs/tags/v2": testTagObj + "\n",
+				".git/objects/" + testTagObj[:2] + "/" + testTagObj[2:]: annotated,
+				"mdm/commands/a.yaml": "",
+			},
+			path: "mdm/commands/a.yaml",
+			want: Revision{Commit: testCommit, Tag: "v2"},
+		},
+		{
+			name: "worktree",
+			files: map[string]string{
+				"main/.git/worktrees/wt/HEAD":      "ref: refs/heads/main\n",
+				"main/.git/worktrees/wt/commondir": "../..\n",
+				"main/.git/refs/heads/main":        testCommit + "\n",
+				"wt/.git":                          "gitdir: ../main/.git/worktrees/wt\n",
+				"wt/mdm/commands/a.yaml":           "",
+			},
+			path: "wt/mdm/commands/a.yaml",
+			want: Revision{Commit: testCommit},
+		},
+		{
+			name: "vendored schema",
+			files: map[string]string{
+				".git/HEAD":                       testCommit + "\n",
+				"third_party/mdm/commands/a.yaml": "",
+			},
+			path:    "third_party/mdm/commands/a.yaml",
+			wantErr: ErrNotSchemaRepository,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			rev, err := GitRevision(filepath.Join(dir, filepath.FromSlash(tc.path)))
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("have error %v, want %v", err, tc.wantErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if rev != tc.want {
+				t.Errorf("have %+v, want %+v", rev, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package admgen
+
+import (
+	"github.com/dave/jennifer/jen"
+)
+
+// JenServerToken generates the unexported functions used to compute
+// declaration ServerTokens into file, each named with prefix:
+//
+//	<prefix>CanonicalJSON(declType, identifier string, payload interface{}) ([]byte, error)
+//	<prefix>ServerToken(declType, identifier string, payload interface{}) (string, error)
+//
+// Both the untyped and typed declarations generated from the schema call
+// these so that equal declarations get equal tokens however they are
+// represented. The prefix keeps the functions of different generators
+// distinct when they share a package.
+func JenServerToken(file *jen.File, prefix string) {
+	canonical := prefix + "CanonicalJSON"
+	normalize := prefix + "NormalizeNumbers"
+
+	file.Comment(canonical + " returns the canonical serialization of a declaration used")
+	file.Comment("to compute its ServerToken. Object keys are sorted, insignificant whitespace")
+	file.Comment("is removed, and numbers are normalized. The ServerToken itself is excluded.")
+	file.Func().Id(canonical).Params(
+		jen.List(jen.Id("declType"), jen.Id("identifier")).String(),
+		jen.Id("payload").Interface(),
+	).Params(jen.Index().Byte(), jen.Error()).Block(
+		jen.Comment("round-trip through JSON so that any structs, typed maps and"),
+		jen.Comment("slices, or numbers in the payload are all represented the same way"),
+		jen.List(jen.Id("b"), jen.Id("err")).Op(":=").Qual("encoding/json", "Marshal").Call(jen.Map(jen.String()).Interface().Values(jen.Dict{
+			jen.Lit("Type"):       jen.Id("declType"),
+			jen.Lit("Identifier"): jen.Id("identifier"),
+			jen.Lit("Payload"):    jen.Id("payload"),
+		})),
+		jen.If(jen.Id("err").Op("!=").Nil()).Block(
+			jen.Return(jen.Nil(), jen.Id("err")),
+		),
+		jen.Id("dec").Op(":=").Qual("encoding/json", "NewDecoder").Call(jen.Qual("bytes", "NewReader").Call(jen.Id("b"))),
+		jen.Id("dec").Dot("UseNumber").Call(),
+		jen.Var().Id("norm").Interface(),
+		jen.If(jen.Id("err").Op("=").Id("dec").Dot("Decode").Call(jen.Op("&").Id("norm")), jen.Id("err").Op("!=").Nil()).Block(
+			jen.Return(jen.Nil(), jen.Id("err")),
+		),
+		jen.Id("buf").Op(":=").New(jen.Qual("bytes", "Buffer")),
+		jen.Id("enc").Op(":=").Qual("encoding/json", "NewEncoder").Call(jen.Id("buf")),
+		jen.Id("enc").Dot("SetEscapeHTML").Call(jen.False()),
+		jen.If(jen.Id("err").Op("=").Id("enc").Dot("Encode").Call(jen.Id(normalize).Call(jen.Id("norm"))), jen.Id("err").Op("!=").Nil()).Block(
+			jen.Return(jen.Nil(), jen.Id("err")),
+		),
+		jen.Return(jen.Qual("bytes", "TrimSuffix").Call(jen.Id("buf").Dot("Bytes").Call(), jen.Index().Byte().Call(jen.Lit("\n"))), jen.Nil()),
+	)
+
+	file.Comment(prefix + "ServerToken returns a ServerToken that changes whenever the type,")
+	file.Comment("identifier, or payload of a declaration changes. Identical declarations")
+	file.Comment("always produce identical tokens.")
+	file.Func().Id(prefix+"ServerToken").Params(
+		jen.List(jen.Id("declType"), jen.Id("identifier")).String(),
+		jen.Id("payload").Interface(),
+	).Params(jen.String(), jen.Error()).Block(
+		jen.List(jen.Id("b"), jen.Id("err")).Op(":=").Id(canonical).Call(jen.Id("declType"), jen.Id("identifier"), jen.Id("payload")),
+		jen.If(jen.Id("err").Op("!=").Nil()).Block(
+			jen.Return(jen.Lit(""), jen.Id("err")),
+		),
+		jen.Id("sum").Op(":=").Qual("crypto/sha256", "Sum256").Call(jen.Id("b")),
+		jen.Return(jen.Qual("encoding/hex", "EncodeToString").Call(jen.Id("sum").Index(jen.Empty(), jen.Empty())), jen.Nil()),
+	)
+
+	file.Comment(normalize + " converts JSON numbers in v so that equal values")
+	file.Comment("encode identically (e.g. 1, 1.0, and 1e0).")
+	file.Func().Id(normalize).Params(jen.Id("v").Interface()).Interface().Block(
+		jen.Switch(jen.Id("t").Op(":=").Id("v").Assert(jen.Type())).Block(
+			jen.Case(jen.Qual("encoding/json", "Number")).Block(
+				jen.If(jen.List(jen.Id("i"), jen.Id("err")).Op(":=").Id("t").Dot("Int64").Call(), jen.Id("err").Op("==").Nil()).Block(
+					jen.Return(jen.Id("i")),
+				),
+				jen.List(jen.Id("f"), jen.Id("err")).Op(":=").Id("t").Dot("Float64").Call(),
+				jen.If(jen.Id("err").Op("!=").Nil()).Block(
+					jen.Return(jen.Id("t")),
+				),
+				jen.If(jen.Id("f").Op("==").Qual("math", "Trunc").Call(jen.Id("f")).Op("&&").Qual("math", "Abs").Call(jen.Id("f")).Op("<").Lit(1).Op("<<").Lit(53)).Block(
+					jen.Return(jen.Int64().Call(jen.Id("f"))),
+				),
+				jen.Return(jen.Id("f")),
+			),
+			jen.Case(jen.Map(jen.String()).Interface()).Block(
+				jen.For(jen.List(jen.Id("k"), jen.Id("mv")).Op(":=").Range().Id("t")).Block(
+					jen.Id("t").Index(jen.Id("k")).Op("=").Id(normalize).Call(jen.Id("mv")),
+				),
+			),
+			jen.Case(jen.Index().Interface()).Block(
+				jen.For(jen.Id("i").Op(":=").Range().Id("t")).Block(
+					jen.Id("t").Index(jen.Id("i")).Op("=").Id(normalize).Call(jen.Id("t").Index(jen.Id("i"))),
+				),
+			),
+		),
+		jen.Return(jen.Id("v")),
+	)
+}
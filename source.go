@@ -0,0 +1,283 @@
+package admgen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source reads schema files from the local filesystem, a git
+// repository at a given ref, or a release archive.
+type Source struct {
+	fsys fs.FS
+
+	// Revision is the schema revision of the source, if known.
+	Revision Revision
+}
+
+// OpenSource opens the schema source described by spec. An empty spec
+// uses the local filesystem. A spec ending in ".zip", ".tar", ".tar.gz",
+// or ".tgz" is read as an archive. Otherwise the spec is a local git
+// repository and ref in the form "<repo-dir>@<ref>".
+// For archives and git repositories file names are relative to the
+// root of the repository.
+func OpenSource(spec string) (*Source, error) {
+	switch {
+	case spec == "":
+		return &Source{}, nil
+	case strings.HasSuffix(spec, ".zip"):
+		return openZip(spec)
+	case strings.HasSuffix(spec, ".tar"), strings.HasSuffix(spec, ".tar.gz"), strings.HasSuffix(spec, ".tgz"):
+		f, err := os.Open(spec)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readTar(f, !strings.HasSuffix(spec, ".tar"))
+	}
+	dir, ref, ok := splitGitSpec(spec)
+	if !ok {
+		return nil, fmt.Errorf("invalid source: %s", spec)
+	}
+	return openGit(dir, ref)
+}
+
+// splitGitSpec splits spec at the first "@" following an existing
+// directory so that refs may contain "@" (e.g. "HEAD@{1}").
+func splitGitSpec(spec string) (dir, ref string, ok bool) {
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '@' || i == 0 || i == len(spec)-1 {
+			continue
+		}
+		if fi, err := os.Stat(spec[:i]); err == nil && fi.IsDir() {
+			return spec[:i], spec[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// ReadFile reads the named file.
+func (s *Source) ReadFile(name string) ([]byte, error) {
+	if s == nil || s.fsys == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(s.fsys, fsName(name))
+}
+
+// WalkDir walks the file tree rooted at root calling fn for each
+// file or directory in the tree.
+func (s *Source) WalkDir(root string, fn fs.WalkDirFunc) error {
+	if s == nil || s.fsys == nil {
+		return filepath.WalkDir(root, fn)
+	}
+	return fs.WalkDir(s.fsys, fsName(root), fn)
+}
+
+// fsName converts a file name into an fs.FS name.
+func fsName(name string) string {
+	name = path.Clean(filepath.ToSlash(name))
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// openZip opens a zip archive. Archives created with git (including
+// GitHub release archives) record the commit as the zip comment.
+func openZip(name string) (*Source, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	fsys, err := stripTopDir(zr)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{fsys: fsys, Revision: Revision{Commit: strings.TrimSpace(zr.Comment)}}, nil
+}
+
+// stripTopDir returns the single top-level directory of fsys, if there
+// is one. Archives created without a prefix (e.g. by "git archive") are
+// returned as-is: their single top-level directory is a schema directory.
+func stripTopDir(fsys fs.FS) (fs.FS, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() && !schemaDirs[entries[0].Name()] {
+		return fs.Sub(fsys, entries[0].Name())
+	}
+	return fsys, nil
+}
+
+// readTar reads a (possibly gzipped) tar archive into memory. Archives
+// created with git record the commit in a global header comment.
+func readTar(r io.Reader, gzipped bool) (*Source, error) {
+	if gzipped {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	m := make(memFS)
+	src := &Source{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader:
+			src.Revision.Commit = hdr.PAXRecords["comment"]
+		case tar.TypeReg:
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			m[fsName(hdr.Name)] = b
+		}
+	}
+	var err error
+	src.fsys, err = stripTopDir(m)
+	return src, err
+}
+
+// openGit reads the repository at dir at ref directly from its git
+// object database. Neither git nor network access is needed.
+func openGit(dir, ref string) (*Source, error) {
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.close()
+	commit, err := repo.resolve(ref)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	tree, err := repo.peel(commit, "tree")
+	if err != nil {
+		return nil, err
+	}
+	m := make(memFS)
+	if err = repo.readTree(tree, "", m); err != nil {
+		return nil, fmt.Errorf("reading tree of %s: %w", commit, err)
+	}
+	src := &Source{fsys: m, Revision: Revision{Commit: commit}}
+	src.Revision.Tag, err = findTag(repo, commit)
+	return src, err
+}
+
+// memFS is a read-only in-memory filesystem of file names to contents.
+// Directories are implied by the file names.
+type memFS map[string][]byte
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if b, ok := m[name]; ok {
+		return &memFile{info: memInfo{name: path.Base(name), size: int64(len(b))}, r: bytes.NewReader(b)}, nil
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for fileName, b := range m {
+		if !strings.HasPrefix(fileName, prefix) {
+			continue
+		}
+		child, _, isDir := strings.Cut(fileName[len(prefix):], "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		info := memInfo{name: child, size: int64(len(b))}
+		if isDir {
+			info = memInfo{name: child, dir: true}
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	if len(entries) < 1 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &memDir{memInfo: memInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// memInfo implements fs.FileInfo for memFS.
+type memInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() interface{}   { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+type memFile struct {
+	info memInfo
+	r    *bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	memInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.memInfo, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 || n >= len(d.entries) {
+		entries := d.entries
+		d.entries = nil
+		if n > 0 && len(entries) < 1 {
+			return nil, io.EOF
+		}
+		return entries, nil
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
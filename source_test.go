@@ -0,0 +1,290 @@
+package admgen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemFS(t *testing.T) {
+	m := memFS{
+		"a.yaml":                 []byte("a"),
+		"mdm/commands/b.yaml":    []byte("b"),
+		"mdm/commands/c.yaml":    []byte("c"),
+		"declarative/status/d.y": []byte("d"),
+	}
+	if err := fstest.TestFS(m, "a.yaml", "mdm/commands/b.yaml", "mdm/commands/c.yaml", "declarative/status/d.y"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStripTopDir(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"prefixed", []string{"device-management-1/mdm/a.yaml", "device-management-1/other/b.yaml"}, "mdm/a.yaml"},
+		{"not prefixed", []string{"mdm/a.yaml", "other/b.yaml"}, "mdm/a.yaml"},
+		{"single schema dir", []string{"mdm/a.yaml"}, "mdm/a.yaml"},
+		{"top-level file", []string{"x/mdm/a.yaml", "README.md"}, "x/mdm/a.yaml"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := make(memFS)
+			for _, name := range tc.files {
+				m[name] = []byte(name)
+			}
+			fsys, err := stripTopDir(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = fs.Stat(fsys, tc.want); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSplitGitSpec(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo@x")
+	if err := os.Mkdir(repo, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		spec, dir, ref string
+		ok             bool
+	}{
+		{repo + "@main", repo, "main", true},
+		{repo + "@HEAD@{1}", repo, "HEAD@{1}", true},
+		{repo + "@main@{yesterday}", repo, "main@{yesterday}", true},
+		{dir + "@main", dir, "main", true},
+		{filepath.Join(dir, "missing") + "@main", "", "", false},
+		{repo + "@", "", "", false},
+	} {
+		d, ref, ok := splitGitSpec(tc.spec)
+		if d != tc.dir || ref != tc.ref || ok != tc.ok {
+			t.Errorf("%s: have %q %q %v, want %q %q %v", tc.spec, d, ref, ok, tc.dir, tc.ref, tc.ok)
+		}
+	}
+}
+
+func TestReadArchives(t *testing.T) {
+	files := map[string]string{"mdm/commands/a.yaml": "a", "other/b.yaml": "b"}
+	const commit = "1111111111111111111111111111111111111111"
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": commit}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "dm-1/" + name, Size: int64(len(content)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	gw.Write(tarBuf.Bytes())
+	gw.Close()
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for name, content := range files {
+		w, err := zw.Create("dm-1/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.SetComment(commit)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for name, b := range map[string][]byte{"s.tar": tarBuf.Bytes(), "s.tar.gz": gzBuf.Bytes(), "s.zip": zipBuf.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, b, 0666); err != nil {
+				t.Fatal(err)
+			}
+			src, err := OpenSource(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src.Revision.Commit != commit {
+				t.Errorf("commit: have %q, want %q", src.Revision.Commit, commit)
+			}
+			for name, content := range files {
+				if b, err := src.ReadFile(name); err != nil || string(b) != content {
+					t.Errorf("%s: have %q (%v), want %q", name, b, err, content)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("0123456789")
+	for _, tc := range []struct {
+		name  string
+		delta []byte
+		want  string
+		err   bool
+	}{
+		{"insert", []byte{10, 3, 3, 'a', 'b', 'c'}, "abc", false},
+		// copy 4 bytes at offset 2
+		{"copy", []byte{10, 4, 0x91, 2, 4}, "2345", false},
+		{"copy and insert", []byte{10, 6, 0x90, 3, 3, 'x', 'y', 'z'}, "012xyz", false},
+		{"wrong base size", []byte{9, 3, 3, 'a', 'b', 'c'}, "", true},
+		{"wrong result size", []byte{10, 4, 3, 'a', 'b', 'c'}, "", true},
+		{"copy out of range", []byte{10, 4, 0x91, 8, 4}, "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			have, err := applyDelta(base, tc.delta)
+			if tc.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if string(have) != tc.want {
+				t.Errorf("have %q, want %q", have, tc.want)
+			}
+		})
+	}
+}
+
+// git runs git in dir for creating test repositories.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// archiveFiles returns the files of rev using git archive.
+func archiveFiles(t *testing.T, dir, rev string) map[string]string {
+	t.Helper()
+	cmd := exec.Command("git", "archive", "--format=tar", rev)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(out))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			var b bytes.Buffer
+			b.ReadFrom(tr)
+			files[hdr.Name] = b.String()
+		}
+	}
+	return files
+}
+
+func TestOpenGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	var commits []string
+	for i := 0; i < 3; i++ {
+		// large, similar files are stored as deltas once packed
+		var b strings.Builder
+		for j := 0; j < 200; j++ {
+			fmt.Fprintf(&b, "- key: Key%d\n  type: <string>\n  content: revision %d\n", j, i*(j%7))
+		}
+		writeFiles(t, dir, map[string]string{
+			"mdm/commands/big.yaml":       b.String(),
+			"declarative/status/s.yaml":   fmt.Sprintf("revision: %d\n", i),
+			"other/nested/deep/file.yaml": "unchanged\n",
+		})
+		git(t, dir, "add", "-A")
+		git(t, dir, "commit", "-q", "-m", fmt.Sprint(i))
+		commits = append(commits, git(t, dir, "rev-parse", "HEAD"))
+	}
+	git(t, dir, "tag", "-a", "-m", "release", "v1", commits[1])
+
+	for _, packed := range []bool{false, true} {
+		if packed {
+			git(t, dir, "gc", "-q", "--aggressive")
+		}
+		for _, tc := range []struct {
+			ref    string
+			commit string
+			tag    string
+		}{
+			{"HEAD", commits[2], ""},
+			{"main", commits[2], ""},
+			{"refs/heads/main", commits[2], ""},
+			{"v1", commits[1], "v1"},
+			{"HEAD@{2}", commits[0], ""},
+			{"main@{1}", commits[1], "v1"},
+			{commits[0][:8], commits[0], ""},
+			{commits[0], commits[0], ""},
+		} {
+			t.Run(fmt.Sprintf("%s packed=%v", tc.ref, packed), func(t *testing.T) {
+				src, err := OpenSource(dir + "@" + tc.ref)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := (Revision{Commit: tc.commit, Tag: tc.tag}); src.Revision != want {
+					t.Errorf("revision: have %+v, want %+v", src.Revision, want)
+				}
+				want := archiveFiles(t, dir, tc.commit)
+				n := 0
+				err = src.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+					if err != nil || d.IsDir() {
+						return err
+					}
+					n++
+					b, err := src.ReadFile(path)
+					if err != nil {
+						return err
+					}
+					if string(b) != want[path] {
+						t.Errorf("%s differs from git archive", path)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != len(want) {
+					t.Errorf("have %d files, want %d", n, len(want))
+				}
+			})
+		}
+	}
+
+	if _, err := OpenSource(dir + "@nope"); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+}